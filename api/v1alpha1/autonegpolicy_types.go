@@ -0,0 +1,78 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AutonegPolicySpec defines the backend services namespaces may register
+// their NEGs with
+type AutonegPolicySpec struct {
+	// Rules allow namespaces to use backend services. A backend service is
+	// allowed if any rule of any policy allows it.
+	Rules []AutonegPolicyRule `json:"rules"`
+}
+
+// AutonegPolicyRule allows the namespaces it selects to use the backend
+// services matching all of its patterns, regions and projects
+type AutonegPolicyRule struct {
+	// Namespaces lists the names of the namespaces the rule applies to
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector selects the namespaces the rule applies to by
+	// their labels. A rule without namespaces and selector applies to all
+	// namespaces.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// BackendServices are regular expressions matching the whole name of
+	// allowed backend services
+	BackendServices []string `json:"backendServices"`
+	// Regions lists the allowed regions of backend services, "global" for
+	// global backend services. All regions are allowed if empty.
+	// +optional
+	Regions []string `json:"regions,omitempty"`
+	// Projects lists the allowed projects of backend services. All
+	// projects are allowed if empty.
+	// +optional
+	Projects []string `json:"projects,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// AutonegPolicy restricts the backend services the services of namespaces
+// may register their NEGs with
+type AutonegPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AutonegPolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AutonegPolicyList contains a list of AutonegPolicy
+type AutonegPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AutonegPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AutonegPolicy{}, &AutonegPolicyList{})
+}
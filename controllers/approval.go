@@ -0,0 +1,212 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// autonegPendingPlanAnnotation holds the changes of protected backend
+	// services waiting for approval
+	autonegPendingPlanAnnotation = "controller.autoneg.dev/pending-plan"
+	// autonegApprovedPlanAnnotation approves the pending plan with the
+	// given hash, it is removed once the plan is applied
+	autonegApprovedPlanAnnotation = "controller.autoneg.dev/approved-plan"
+)
+
+// ChangePlan holds the changes of protected backend services a
+// reconciliation applies
+type ChangePlan struct {
+	Hash    string                 `json:"hash"`
+	Changes []BackendServiceChange `json:"changes"`
+}
+
+// BackendServiceChange holds the changes of the backends of a backend
+// service. Backend groups are given as zone/NEG.
+type BackendServiceChange struct {
+	Name   string            `json:"name"`
+	Region string            `json:"region,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+	Upsert []compute.Backend `json:"upsert,omitempty"`
+}
+
+// parseProtectedBackendServices parses the patterns of protected backend
+// service names, separated by whitespace. Each pattern is a regular
+// expression matching the whole name.
+func parseProtectedBackendServices(value string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, p := range strings.Fields(value) {
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %q is not a regular expression: %v", errConfigInvalid, controllerConfigProtectedBackendServices, p, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// isProtected returns true if the backend service name matches one of the
+// patterns
+func isProtected(patterns []*regexp.Regexp, name string) bool {
+	return slices.ContainsFunc(patterns, func(re *regexp.Regexp) bool { return re.MatchString(name) })
+}
+
+// planGroup shortens the URL of a NEG to zone/NEG
+func planGroup(group string) string {
+	return groupZone(group) + "/" + path.Base(group)
+}
+
+// errApprovalRequired is returned when protected backend services would
+// change without the approval of the plan of the changes
+type errApprovalRequired struct {
+	Plan *ChangePlan
+}
+
+func (e *errApprovalRequired) Error() string {
+	return fmt.Sprintf("changes of protected backend services %s wait for approval of plan %s", strings.Join(e.Plan.backendServices(), ", "), e.Plan.Hash)
+}
+
+// protectedPatch is a patch of a protected backend service waiting for the
+// approval of its plan
+type protectedPatch struct {
+	change        BackendServiceChange
+	svc           *compute.BackendService
+	forceCapacity map[int]bool
+}
+
+// snapshotBackends copies the backends of a backend service by group, to
+// compare them with the patched backends
+func snapshotBackends(svc *compute.BackendService) map[string]compute.Backend {
+	backends := make(map[string]compute.Backend, len(svc.Backends))
+	for _, be := range svc.Backends {
+		backends[be.Group] = *be
+	}
+	return backends
+}
+
+// backendServiceChange returns the backends a patch removes from a backend
+// service and the ones it adds or changes, or nil if it changes none. The
+// changes include those of drains, traffic splits and enforced drift.
+func backendServiceChange(name, region string, before map[string]compute.Backend, after []*compute.Backend) *BackendServiceChange {
+	change := &BackendServiceChange{Name: name, Region: region}
+	groups := map[string]bool{}
+	for _, be := range after {
+		groups[be.Group] = true
+		if old, ok := before[be.Group]; ok && reflect.DeepEqual(old, *be) {
+			continue
+		}
+		upsert := *be
+		upsert.Group = planGroup(be.Group)
+		change.Upsert = append(change.Upsert, upsert)
+	}
+	for group := range before {
+		if !groups[group] {
+			change.Remove = append(change.Remove, planGroup(group))
+		}
+	}
+	if len(change.Remove) == 0 && len(change.Upsert) == 0 {
+		return nil
+	}
+	slices.Sort(change.Remove)
+	slices.SortFunc(change.Upsert, func(a, b compute.Backend) int { return strings.Compare(a.Group, b.Group) })
+	return change
+}
+
+// newChangePlan returns the plan of the changes, whose hash identifies it in
+// approvals
+func newChangePlan(changes []BackendServiceChange) *ChangePlan {
+	plan := &ChangePlan{Changes: slices.Clone(changes)}
+	slices.SortStableFunc(plan.Changes, func(a, b BackendServiceChange) int {
+		return strings.Compare(a.Region+"/"+a.Name, b.Region+"/"+b.Name)
+	})
+	data, _ := json.Marshal(plan.Changes)
+	plan.Hash = fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+	return plan
+}
+
+// backendServices returns the names of the backend services of the plan
+func (p *ChangePlan) backendServices() []string {
+	names := make([]string, 0, len(p.Changes))
+	for _, c := range p.Changes {
+		names = append(names, c.Name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// approvedPlan returns the hash of the plan approved by the annotation of
+// the service
+func approvedPlan(svc *corev1.Service) string {
+	return strings.TrimSpace(svc.Annotations[autonegApprovedPlanAnnotation])
+}
+
+// awaitApproval records the plan in the pending plan annotation and the
+// Synced condition. Setting the approval annotation to the hash of the plan
+// reconciles the service again, which applies the plan.
+func (r *ServiceReconciler) awaitApproval(ctx context.Context, logger logr.Logger, svc *corev1.Service, errorKey string, plan *ChangePlan) (reconcile.Result, error) {
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return r.reconcileResult(ctx, logger, svc, errorKey, err)
+	}
+	message := fmt.Sprintf("Changes of protected backend services %s wait for approval; set the %s annotation to %q to apply them", strings.Join(plan.backendServices(), ", "), autonegApprovedPlanAnnotation, plan.Hash)
+	if approved, ok := svc.Annotations[autonegApprovedPlanAnnotation]; ok {
+		message = fmt.Sprintf("%s, the approved plan %q is outdated", message, approved)
+	}
+	if svc.Annotations[autonegPendingPlanAnnotation] != string(data) {
+		logger.Info("Changes of protected backend services wait for approval", "hash", plan.Hash, "backendServices", plan.backendServices())
+		svc.Annotations[autonegPendingPlanAnnotation] = string(data)
+		if err = r.Update(ctx, svc); err != nil {
+			if apierrors.IsConflict(err) {
+				logger.Info("Conflict updating service; requeueing", "error", err.Error())
+				return reconcile.Result{RequeueAfter: 1 * time.Second}, nil
+			}
+			return r.reconcileResult(ctx, logger, svc, errorKey, err)
+		}
+		r.Recorder.Event(svc, "Normal", "ApprovalRequired", message)
+	}
+	if err = r.setCondition(ctx, svc, conditionSynced, metav1.ConditionFalse, "ApprovalRequired", message); err != nil {
+		logger.Error(err, "Failed to update service status")
+	}
+	return r.reconcileResult(ctx, logger, svc, errorKey, nil)
+}
+
+// clearPlan removes the pending plan and its approval, and returns true if
+// the service had any of them
+func clearPlan(svc *corev1.Service) bool {
+	_, pending := svc.Annotations[autonegPendingPlanAnnotation]
+	_, approved := svc.Annotations[autonegApprovedPlanAnnotation]
+	delete(svc.Annotations, autonegPendingPlanAnnotation)
+	delete(svc.Annotations, autonegApprovedPlanAnnotation)
+	return pending || approved
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// protectedTestServer serves the backend service "test" with the given
+// backends, and records the backends of patches
+func protectedTestServer(t *testing.T, backends func() []*compute.Backend, patched *[]*compute.Backend) *compute.Service {
+	s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case http.MethodPatch:
+			var body compute.BackendService
+			json.NewDecoder(req.Body).Decode(&body)
+			*patched = body.Backends
+			json.NewEncoder(res).Encode(compute.Operation{Name: "op", Status: computeOperationStatusDone})
+		case http.MethodGet:
+			json.NewEncoder(res).Encode(compute.BackendService{Name: "test", Backends: backends()})
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+	}))
+	t.Cleanup(s.Close)
+	cs, err := compute.NewService(context.Background(), option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to instantiate compute service: %v", err)
+	}
+	return cs
+}
+
+func TestReconcileBackendsProtected(t *testing.T) {
+	protected, err := parseProtectedBackendServices("te.t prod-.*")
+	if err != nil {
+		t.Fatalf("parseProtectedBackendServices() got err: %v", err)
+	}
+	unknown := getGroup(fakeProject, "zone9", fakeNeg)
+	oneZone := statusBasicWithNEGs
+	oneZone.NEGStatus = NEGStatus{NEGs: negStatus.NEGs, Zones: []string{"zone1"}}
+	rate := statusBasicWithNEGs
+	rate.AutonegConfig = AutonegConfig{BackendServices: map[string]map[string]AutonegNEGConfig{
+		"80": {"test": {Name: "test", Rate: 200}},
+	}}
+	drained := statusBasicWithNEGs
+	drained.Drain = newDrain(ControllerConfig{DrainCluster: true}, nil)
+	enforced := statusBasicWithNEGs
+	enforced.driftPolicy = driftPolicyEnforce
+	split := statusBasicWithNEGs
+	split.trafficSplit = map[string]float64{trafficSplitKey("", "test"): 1}
+
+	tests := []struct {
+		name     string
+		backends []*compute.Backend
+		intended AutonegStatus
+		// want are the removed and upserted groups of the plan
+		want [2][]string
+	}{
+		{
+			name:     "zone removed",
+			intended: oneZone,
+			want:     [2][]string{{"zone2/neg_name"}, nil},
+		},
+		{
+			name:     "rate changed",
+			intended: rate,
+			want:     [2][]string{nil, {"zone1/neg_name", "zone2/neg_name"}},
+		},
+		{
+			name:     "cluster drained",
+			intended: drained,
+			want:     [2][]string{nil, {"zone1/neg_name", "zone2/neg_name"}},
+		},
+		{
+			name:     "unknown backend removed by the drift policy",
+			backends: append(driftTestBackends(nil), &compute.Backend{Group: unknown, BalancingMode: "RATE", MaxRatePerEndpoint: 100}),
+			intended: enforced,
+			want:     [2][]string{{"zone9/neg_name"}, nil},
+		},
+		{
+			name: "capacity of a traffic split",
+			backends: driftTestBackends(func(zone string, be *compute.Backend) {
+				be.CapacityScaler = 0.5
+			}),
+			intended: split,
+			want:     [2][]string{nil, {"zone1/neg_name", "zone2/neg_name"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backends := func() []*compute.Backend {
+				if tt.backends != nil {
+					return tt.backends
+				}
+				return driftTestBackends(nil)
+			}
+			var patched []*compute.Backend
+			bc := ProdBackendController{project: fakeProject, s: protectedTestServer(t, backends, &patched)}
+			intended := tt.intended
+			intended.protected = protected
+
+			_, err := bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, intended, false)
+			var approvalErr *errApprovalRequired
+			if !errors.As(err, &approvalErr) {
+				t.Fatalf("ReconcileBackends() got err %v, want approval required", err)
+			}
+			if patched != nil {
+				t.Errorf("ReconcileBackends() patched %v before approval", patched)
+			}
+			plan := approvalErr.Plan
+			if len(plan.Changes) != 1 || plan.Changes[0].Name != "test" {
+				t.Fatalf("ReconcileBackends() got plan %+v, want changes of test", plan)
+			}
+			var upserts []string
+			for _, be := range plan.Changes[0].Upsert {
+				upserts = append(upserts, be.Group)
+			}
+			if got := [2][]string{plan.Changes[0].Remove, upserts}; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReconcileBackends() got plan %v, want %v", got, tt.want)
+			}
+
+			// The same changes have the same plan, which applies once
+			// approved.
+			intended = tt.intended
+			intended.protected = protected
+			intended.approvedPlan = plan.Hash
+			if _, err = bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, intended, false); err != nil {
+				t.Fatalf("ReconcileBackends() with approved plan got err: %v", err)
+			}
+			if patched == nil {
+				t.Errorf("ReconcileBackends() with approved plan did not patch")
+			}
+		})
+	}
+
+	// Unprotected backend services are patched right away.
+	var patched []*compute.Backend
+	bc := ProdBackendController{project: fakeProject, s: protectedTestServer(t, func() []*compute.Backend { return driftTestBackends(nil) }, &patched)}
+	intended := drained
+	intended.protected, _ = parseProtectedBackendServices("prod-.*")
+	if _, err := bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, intended, false); err != nil {
+		t.Fatalf("ReconcileBackends() got err: %v", err)
+	}
+	if patched == nil {
+		t.Errorf("ReconcileBackends() of an unprotected backend service did not patch")
+	}
+	if _, err := parseProtectedBackendServices("prod-("); err == nil {
+		t.Errorf("parseProtectedBackendServices() got no error")
+	}
+}
+
+func TestReconcileApproval(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	applied, err := json.Marshal(statusBasicWithNEGs)
+	if err != nil {
+		t.Fatalf("json.Marshal() got err: %v", err)
+	}
+	r := newTestReconciler(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  key.Namespace,
+			Name:       key.Name,
+			Finalizers: []string{autonegFinalizer},
+			Annotations: map[string]string{
+				autonegAnnotation:       `{"backend_services":{"80":[{"name":"test","max_rate_per_endpoint":100}]}}`,
+				negStatusAnnotation:     `{"network_endpoint_groups":{"80":"neg_name"},"zones":["zone1"]}`,
+				autonegStatusAnnotation: string(applied),
+			},
+		},
+	})
+	var patched []*compute.Backend
+	cs := protectedTestServer(t, func() []*compute.Backend { return driftTestBackends(nil) }, &patched)
+	r.BackendController = &ProdBackendController{project: fakeProject, s: cs}
+	r.ControllerConfigMap = types.NamespacedName{Namespace: "autoneg-system", Name: "autoneg-controller-config"}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: r.ControllerConfigMap.Namespace, Name: r.ControllerConfigMap.Name},
+		Data:       map[string]string{controllerConfigProtectedBackendServices: "test"},
+	}
+	if err := r.Create(ctx, cm); err != nil {
+		t.Fatalf("Create() got err: %v", err)
+	}
+	req := ctrl.Request{NamespacedName: key}
+	getService := func() *corev1.Service {
+		svc := &corev1.Service{}
+		if err := r.Get(ctx, key, svc); err != nil {
+			t.Fatalf("Get() got err: %v", err)
+		}
+		return svc
+	}
+
+	// Removing a zone from the protected backend service waits for approval.
+	for range 2 {
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatalf("Reconcile() got err: %v", err)
+		}
+	}
+	if patched != nil {
+		t.Errorf("Reconcile() patched %v before approval", patched)
+	}
+	svc := getService()
+	var plan ChangePlan
+	if err := json.Unmarshal([]byte(svc.Annotations[autonegPendingPlanAnnotation]), &plan); err != nil {
+		t.Fatalf("failed to decode plan: %v", err)
+	}
+	if len(plan.Changes) != 1 || !reflect.DeepEqual(plan.Changes[0].Remove, []string{"zone2/neg_name"}) {
+		t.Errorf("Reconcile() recorded plan %+v, want removal of zone2/neg_name", plan)
+	}
+	if c := meta.FindStatusCondition(svc.Status.Conditions, conditionSynced); c == nil || c.Status != metav1.ConditionFalse || c.Reason != "ApprovalRequired" {
+		t.Errorf("Synced condition = %+v, want False with reason ApprovalRequired", c)
+	}
+
+	// An approval of another plan does not apply it.
+	svc.Annotations[autonegApprovedPlanAnnotation] = "0123456789abcdef"
+	if err := r.Update(ctx, svc); err != nil {
+		t.Fatalf("Update() got err: %v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+	if patched != nil {
+		t.Errorf("Reconcile() patched %v with the approval of another plan", patched)
+	}
+
+	// An operator approves the plan.
+	svc = getService()
+	svc.Annotations[autonegApprovedPlanAnnotation] = plan.Hash
+	if err := r.Update(ctx, svc); err != nil {
+		t.Fatalf("Update() got err: %v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+	if len(patched) != 1 || patched[0].Group != getGroup(fakeProject, "zone1", fakeNeg) {
+		t.Errorf("Reconcile() patched %v, want the backend of zone1", patched)
+	}
+	svc = getService()
+	for _, annotation := range []string{autonegPendingPlanAnnotation, autonegApprovedPlanAnnotation} {
+		if _, ok := svc.Annotations[annotation]; ok {
+			t.Errorf("Service still has the %s annotation", annotation)
+		}
+	}
+	if !meta.IsStatusConditionTrue(svc.Status.Conditions, conditionSynced) {
+		t.Errorf("Service is not Synced")
+	}
+}
@@ -22,12 +22,12 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"math"
 	"regexp"
 	"slices"
 	"sort"
 	"time"
 
-	backoff "github.com/cenkalti/backoff/v5"
 	"github.com/go-logr/logr"
 	"google.golang.org/api/compute/v1"
 	"google.golang.org/api/googleapi"
@@ -44,16 +44,24 @@ const (
 	negAnnotation                 = "cloud.google.com/neg"
 	autonegFinalizer              = "controller.autoneg.dev/neg"
 	autonegSyncAnnotation         = "controller.autoneg.dev/sync"
+	autonegResumeAnnotation       = "controller.autoneg.dev/resume"
+	autonegDriftPolicyAnnotation  = "controller.autoneg.dev/drift-policy"
 	computeOperationStatusDone    = "DONE"
 	computeOperationStatusRunning = "RUNNING"
 	computeOperationStatusPending = "PENDING"
-	maxElapsedTime                = 4 * time.Minute
+	operationPollInterval         = 5 * time.Second
+	retryInitialInterval          = 1 * time.Second
+	retryMaxInterval              = 5 * time.Minute
+	permanentErrorExpiry          = 1 * time.Hour
 )
 
 var (
 	errConfigInvalid = errors.New("autoneg configuration invalid")
 	errJSONInvalid   = errors.New("json malformed")
 	zoneRE           = regexp.MustCompile(`zones/([^/]+)`)
+
+	errOperationPending = errors.New("operation pending")
+	errOperationRunning = errors.New("operation running")
 )
 
 type errNotFound struct {
@@ -64,6 +72,16 @@ func (e *errNotFound) Error() string {
 	return "backend service not found"
 }
 
+// errOperationsPending is returned by ReconcileBackends when backend services
+// were patched, but the compute operations have not finished yet.
+type errOperationsPending struct {
+	Operations []AutonegOperation
+}
+
+func (e *errOperationsPending) Error() string {
+	return fmt.Sprintf("%d compute operations pending", len(e.Operations))
+}
+
 // BackendCustomMetric return a compute.BackendCustomMetric pointer
 func (acm AutonegCustomMetric) BackendCustomMetric() *compute.BackendCustomMetric {
 	var bcm = compute.BackendCustomMetric{
@@ -80,6 +98,21 @@ func (acm AutonegCustomMetric) BackendCustomMetric() *compute.BackendCustomMetri
 func (s AutonegStatus) Backend(name string, port string, group string) compute.Backend {
 	cfg := s.AutonegConfig.BackendServices[port][name]
 
+	// Apply the overrides of the zone of the NEG, keeping the balancing
+	// mode of the backend service
+	if zc, ok := cfg.Zones[groupZone(group)]; ok {
+		if zc.CapacityScaler != nil {
+			cfg.InitialCapacity = nil
+			cfg.CapacityScaler = zc.CapacityScaler
+		}
+		if zc.Rate != nil && len(cfg.CustomMetrics) == 0 && cfg.Rate > 0 {
+			cfg.Rate = *zc.Rate
+		}
+		if zc.Connections != nil && len(cfg.CustomMetrics) == 0 && cfg.Rate <= 0 {
+			cfg.Connections = *zc.Connections
+		}
+	}
+
 	// Extract initial_capacity setting, if set
 	var capacityScaler float64 = 1
 	if capacity := cfg.InitialCapacity; capacity != nil {
@@ -100,8 +133,9 @@ func (s AutonegStatus) Backend(name string, port string, group string) compute.B
 	}
 
 	// Prefer the custom_metrics balancing mode if set, then prefer rate balancing mode if set
+	var be compute.Backend
 	if len(cfg.CustomMetrics) > 0 {
-		return compute.Backend{
+		be = compute.Backend{
 			Group:         group,
 			BalancingMode: "CUSTOM_METRICS",
 			CustomMetrics: slices.Collect(func(yield func(*compute.BackendCustomMetric) bool) {
@@ -114,95 +148,247 @@ func (s AutonegStatus) Backend(name string, port string, group string) compute.B
 			CapacityScaler: capacityScaler,
 		}
 	} else if cfg.Rate > 0 {
-		return compute.Backend{
+		be = compute.Backend{
 			Group:              group,
 			BalancingMode:      "RATE",
 			MaxRatePerEndpoint: float64(cfg.Rate),
 			CapacityScaler:     capacityScaler,
 		}
 	} else {
-		return compute.Backend{
+		be = compute.Backend{
 			Group:                     group,
 			BalancingMode:             "CONNECTION",
 			MaxConnectionsPerEndpoint: int64(cfg.Connections),
 			CapacityScaler:            capacityScaler,
 		}
 	}
+	if cfg.WeightByEndpoints {
+		// Use the group-level setting, so every backend gets a share
+		// proportional to its ready endpoints.
+		ready := float64(max(s.Endpoints[groupZone(group)], 1))
+		switch be.BalancingMode {
+		case "RATE":
+			be.MaxRate = int64(math.Round(be.MaxRatePerEndpoint * ready))
+			be.MaxRatePerEndpoint = 0
+		case "CONNECTION":
+			be.MaxConnections = be.MaxConnectionsPerEndpoint * int64(ready)
+			be.MaxConnectionsPerEndpoint = 0
+		}
+	}
+	be.Failover = cfg.Failover
+	be.Description = cfg.Description
+	return be
 }
 
-// NewBackendController takes the project name and an initialized *compute.Service
-func NewBackendController(project string, s *compute.Service) *ProdBackendController {
+// Backend fields whose ownership is configured in AutonegSyncConfig
+const (
+	syncFieldBalancingMode  = "balancing_mode"
+	syncFieldRates          = "rates"
+	syncFieldCustomMetrics  = "custom_metrics"
+	syncFieldCapacityScaler = "capacity_scaler"
+	syncFieldFailover       = "failover"
+	syncFieldDescription    = "description"
+)
+
+// SyncConfig returns the sync configuration of a backend service: the
+// settings of its configuration entry, defaulting to the ones of the
+// service.
+func (s AutonegStatus) SyncConfig(port string, name string) *AutonegSyncConfig {
+	cfg, ok := s.AutonegConfig.BackendServices[port][name]
+	if !ok || cfg.Sync == nil {
+		return s.AutonegSyncConfig
+	}
+	merged := AutonegSyncConfig{}
+	if s.AutonegSyncConfig != nil {
+		merged = *s.AutonegSyncConfig
+	}
+	override := func(field **bool, value *bool) {
+		if value != nil {
+			*field = value
+		}
+	}
+	override(&merged.CapacityScaler, cfg.Sync.CapacityScaler)
+	override(&merged.BalancingMode, cfg.Sync.BalancingMode)
+	override(&merged.Rates, cfg.Sync.Rates)
+	override(&merged.CustomMetrics, cfg.Sync.CustomMetrics)
+	override(&merged.Failover, cfg.Sync.Failover)
+	override(&merged.Description, cfg.Sync.Description)
+	return &merged
+}
+
+// BackendSyncConfig returns the sync configuration of the backend of a NEG
+// in a backend service. Settings overridden for the zone of the NEG are
+// always synced.
+func (s AutonegStatus) BackendSyncConfig(port string, name string, group string) *AutonegSyncConfig {
+	sync := s.SyncConfig(port, name)
+	zc, ok := s.AutonegConfig.BackendServices[port][name].Zones[groupZone(group)]
+	if !ok {
+		return sync
+	}
+	return zc.ownOverrides(sync)
+}
+
+// ownOverrides returns the sync configuration owning the settings
+// overridden by the zone configuration
+func (zc AutonegZoneConfig) ownOverrides(sync *AutonegSyncConfig) *AutonegSyncConfig {
+	merged := AutonegSyncConfig{}
+	if sync != nil {
+		merged = *sync
+	}
+	owned := true
+	if zc.CapacityScaler != nil {
+		merged.CapacityScaler = &owned
+	}
+	if zc.Rate != nil || zc.Connections != nil {
+		merged.Rates = &owned
+	}
+	return &merged
+}
+
+// groupZone returns the zone of a NEG from its URL
+func groupZone(group string) string {
+	if matches := zoneRE.FindStringSubmatch(group); len(matches) > 1 {
+		return matches[1]
+	}
+	return ""
+}
+
+// Owns returns true if autoneg keeps the given backend field in sync with
+// the configuration. A nil sync configuration uses the defaults.
+func (c *AutonegSyncConfig) Owns(field string) bool {
+	var owned *bool
+	def := true
+	if c != nil {
+		switch field {
+		case syncFieldBalancingMode:
+			owned = c.BalancingMode
+		case syncFieldRates:
+			owned = c.Rates
+		case syncFieldCustomMetrics:
+			owned = c.CustomMetrics
+		case syncFieldCapacityScaler:
+			owned = c.CapacityScaler
+		case syncFieldFailover:
+			owned = c.Failover
+		case syncFieldDescription:
+			owned = c.Description
+		}
+	}
+	switch field {
+	case syncFieldCapacityScaler, syncFieldFailover, syncFieldDescription:
+		def = false
+	}
+	if owned == nil {
+		return def
+	}
+	return *owned
+}
+
+// NewBackendController takes the project name, an initialized *compute.Service
+// and the options of the controller
+func NewBackendController(project string, s *compute.Service, opts BackendControllerOptions) *ProdBackendController {
 	return &ProdBackendController{
-		project: project,
-		s:       s,
+		project:          project,
+		s:                s,
+		readLimiter:      opts.RateLimits.Reads.limiter(),
+		writeLimiter:     opts.RateLimits.Writes.limiter(),
+		operationLimiter: opts.RateLimits.Operations.limiter(),
+		cache:            newBackendServiceCache(opts.Cache),
+		budget:           newChangeBudget(opts.ChangeBudget),
+		permitted:        newPermittedBackendServices(opts.CheckPermissions),
 	}
 }
 
 func (b *ProdBackendController) getBackendService(ctx context.Context, name string, region string) (svc *compute.BackendService, err error) {
 	logger := log.FromContext(ctx)
+	if err = b.checkPermissions(ctx, name, region); err != nil {
+		logger.Info("Missing permissions on gcp backend service", "project", b.project, "region", region, "name", name, "error", err.Error())
+		return nil, err
+	}
+	get := func(etag string) (*compute.BackendService, error) {
+		if err := b.wait(ctx, computeCallRead); err != nil {
+			return nil, err
+		}
+		if region == "" {
+			c := compute.NewBackendServicesService(b.s).Get(b.project, name)
+			if etag != "" {
+				c.IfNoneMatch(etag)
+			}
+			svc, err := c.Do()
+			b.observeCall(err)
+			return svc, err
+		}
+		c := compute.NewRegionBackendServicesService(b.s).Get(b.project, region, name)
+		if etag != "" {
+			c.IfNoneMatch(etag)
+		}
+		svc, err := c.Do()
+		b.observeCall(err)
+		return svc, err
+	}
 	if region == "" {
 		// Log the attempt to get global backend service
 		logger.V(1).Info("Checking gcp global backend service", "project", b.project, "name", name)
-		svc, err = compute.NewBackendServicesService(b.s).Get(b.project, name).Do()
-		if e, ok := err.(*googleapi.Error); ok {
-			if e.Code == 404 {
-				logger.V(1).Info("No gcp global backend service found", "project", b.project, "name", name)
-				err = &errNotFound{Name: name}
-			} else {
-				logger.Error(err, "Failed to get gcp global backend service", "project", b.project, "name", name, "code", e.Code)
-			}
-		} else if err == nil {
-			logger.V(1).Info("Successfully retrieved gcp global backend service", "project", b.project, "name", name, "backends", len(svc.Backends))
-		}
 	} else {
 		// Log the attempt to get regional backend service
 		logger.V(1).Info("Checking gcp regional backend service", "project", b.project, "region", region, "name", name)
-		svc, err = compute.NewRegionBackendServicesService(b.s).Get(b.project, region, name).Do()
-		if e, ok := err.(*googleapi.Error); ok {
-			if e.Code == 404 {
-				logger.V(1).Info("No gcp regional backend service found", "project", b.project, "region", region, "name", name)
-				err = &errNotFound{Name: name}
-			} else {
-				logger.Error(err, "Failed to get gcp regional backend service", "project", b.project, "region", region, "name", name, "code", e.Code)
-			}
-		} else if err == nil {
-			logger.V(1).Info("Successfully retrieved gcp regional backend service", "project", b.project, "region", region, "name", name, "backends", len(svc.Backends))
+	}
+	var cached bool
+	key := backendServiceCacheKey(b.project, region, name)
+	if b.cache != nil {
+		svc, cached, err = b.cachedBackendService(ctx, key, get)
+	}
+	if !cached {
+		svc, err = get("")
+		if err == nil && b.cache != nil {
+			b.cache.put(key, svc)
+		}
+	}
+	b.forgetPermissions(name, region, err)
+	if e, ok := err.(*googleapi.Error); ok {
+		if e.Code == 404 {
+			logger.V(1).Info("No gcp backend service found", "project", b.project, "region", region, "name", name)
+			err = &errNotFound{Name: name}
+		} else {
+			logger.Error(err, "Failed to get gcp backend service", "project", b.project, "region", region, "name", name, "code", e.Code)
 		}
+	} else if err == nil {
+		logger.V(1).Info("Successfully retrieved gcp backend service", "project", b.project, "region", region, "name", name, "backends", len(svc.Backends), "cached", cached)
 	}
 	return
-
 }
 
-func (b *ProdBackendController) compareBackends(left compute.Backend, right compute.Backend) bool {
+func (b *ProdBackendController) compareBackends(left compute.Backend, right compute.Backend, sync *AutonegSyncConfig) bool {
 	// We only compare values we set in Autoneg
 	if left.Group != right.Group {
 		return false
 	}
-	if left.BalancingMode != right.BalancingMode {
+	if sync.Owns(syncFieldBalancingMode) && left.BalancingMode != right.BalancingMode {
 		return false
 	}
-	if left.CapacityScaler != right.CapacityScaler {
+	if sync.Owns(syncFieldCapacityScaler) && left.CapacityScaler != right.CapacityScaler {
 		return false
 	}
-	if left.MaxConnectionsPerEndpoint != right.MaxConnectionsPerEndpoint {
+	if sync.Owns(syncFieldRates) && (left.MaxConnectionsPerEndpoint != right.MaxConnectionsPerEndpoint || left.MaxRatePerEndpoint != right.MaxRatePerEndpoint ||
+		left.MaxConnections != right.MaxConnections || left.MaxRate != right.MaxRate) {
 		return false
 	}
-	if left.MaxRatePerEndpoint != right.MaxRatePerEndpoint {
+	if sync.Owns(syncFieldFailover) && left.Failover != right.Failover {
 		return false
 	}
-	// Assume custom metrics are in the same order
-	if len(left.CustomMetrics) != len(right.CustomMetrics) {
+	if sync.Owns(syncFieldDescription) && left.Description != right.Description {
 		return false
 	}
-	for lk := range left.CustomMetrics {
-		if left.CustomMetrics[lk] != right.CustomMetrics[lk] {
-			return false
-		}
+	// Assume custom metrics are in the same order
+	if sync.Owns(syncFieldCustomMetrics) && !equalCustomMetrics(left.CustomMetrics, right.CustomMetrics) {
+		return false
 	}
 	return true
 }
 
-func (b *ProdBackendController) updateBackends(ctx context.Context, name string, region string, svc *compute.BackendService, forceCapacity map[int]bool, deleting bool) error {
+// updateBackends patches the backend service and returns the compute
+// operation if it has not finished yet.
+func (b *ProdBackendController) updateBackends(ctx context.Context, name string, region string, svc *compute.BackendService, forceCapacity map[int]bool, deleting bool) (*AutonegOperation, error) {
 	logger := log.FromContext(ctx)
 	if len(svc.Backends) == 0 {
 		if deleting {
@@ -222,74 +408,140 @@ func (b *ProdBackendController) updateBackends(ctx context.Context, name string,
 		}
 	}
 	// Perform locking to ensure we patch the intended object version
+	var res *compute.Operation
+	var err error
+	if err = b.wait(ctx, computeCallWrite); err != nil {
+		return nil, err
+	}
 	if region == "" {
 		logger.V(1).Info("Updating gcp global backend service", "project", b.project, "name", name, "backends", len(svc.Backends), "deleting", deleting)
 		p := compute.NewBackendServicesService(b.s).Patch(b.project, name, svc)
 		p.Header().Set("If-match", svc.Header.Get("ETag"))
-		res, err := p.Do()
-		if err != nil {
-			return err
-		}
-		operation := func() (bool, error) {
-			op, err := compute.NewGlobalOperationsService(b.s).Get(b.project, res.Name).Do()
-			if err != nil {
-				return false, err
-			}
-			return true, checkOperation(op)
-		}
-		_, err = backoff.Retry(context.TODO(),
-			operation, backoff.WithBackOff(backoff.NewExponentialBackOff()), backoff.WithMaxElapsedTime(maxElapsedTime))
-		if err != nil {
-			logger.Error(err, "Failed to update gcp global backend service", "project", b.project, "name", name)
-		} else {
-			logger.V(1).Info("Successfully updated gcp global backend service", "project", b.project, "name", name)
-		}
-		return err
+		res, err = p.Do()
 	} else {
 		logger.V(1).Info("Updating gcp regional backend service", "project", b.project, "region", region, "name", name, "backends", len(svc.Backends), "deleting", deleting)
 		p := compute.NewRegionBackendServicesService(b.s).Patch(b.project, region, name, svc)
 		p.Header().Set("If-match", svc.Header.Get("ETag"))
-		res, err := p.Do()
+		res, err = p.Do()
+	}
+	b.observeCall(err)
+	if b.cache != nil {
+		// The backend service changes or is found to be outdated
+		b.cache.invalidate(backendServiceCacheKey(b.project, region, name))
+	}
+	b.forgetPermissions(name, region, err)
+	if err != nil {
+		logger.Error(err, "Failed to update gcp backend service", "project", b.project, "region", region, "name", name)
+		return nil, err
+	}
+
+	// Do not wait for the operation to finish, the caller records it and
+	// checks it on a later reconciliation.
+	err = checkOperation(res)
+	if errors.Is(err, errOperationPending) || errors.Is(err, errOperationRunning) {
+		logger.V(1).Info("Update of gcp backend service in progress", "project", b.project, "region", region, "name", name, "operation", res.Name)
+		return &AutonegOperation{Name: res.Name, Region: region, BackendService: name}, nil
+	}
+	if err != nil {
+		logOperationError(logger, err, "Failed to update gcp backend service", "project", b.project, "region", region, "name", name, "operation", res.Name)
+		return nil, err
+	}
+	logOperationWarnings(logger, res)
+	logger.V(1).Info("Successfully updated gcp backend service", "project", b.project, "region", region, "name", name)
+	return nil, nil
+}
+
+// CheckOperations polls the given compute operations once and returns the
+// ones which are still in progress, or an error if any of them failed.
+func (b *ProdBackendController) CheckOperations(ctx context.Context, ops []AutonegOperation) (pending []AutonegOperation, err error) {
+	logger := log.FromContext(ctx)
+	for _, o := range ops {
+		var op *compute.Operation
+		if err = b.wait(ctx, computeCallOperation); err != nil {
+			return nil, err
+		}
+		if o.Region == "" {
+			op, err = compute.NewGlobalOperationsService(b.s).Get(b.project, o.Name).Do()
+		} else {
+			op, err = compute.NewRegionOperationsService(b.s).Get(b.project, o.Region, o.Name).Do()
+		}
+		b.observeCall(err)
+		if e, ok := err.(*googleapi.Error); ok && e.Code == 404 {
+			// Operations are garbage collected after a while, the following
+			// reconciliation compares the backends anyway.
+			logger.Info("Compute operation not found, assuming it finished", "operation", o.Name, "region", o.Region)
+			err = nil
+			continue
+		}
 		if err != nil {
-			return err
+			return nil, err
 		}
-		operation := func() (bool, error) {
-			op, err := compute.NewRegionOperationsService(b.s).Get(b.project, region, res.Name).Do()
-			if err != nil {
-				return false, err
-			}
-			return true, checkOperation(op)
+		err = checkOperation(op)
+		if errors.Is(err, errOperationPending) || errors.Is(err, errOperationRunning) {
+			logger.V(1).Info("Compute operation still in progress", "operation", o.Name, "region", o.Region, "backendService", o.BackendService)
+			pending = append(pending, o)
+			err = nil
+			continue
 		}
-		_, err = backoff.Retry(context.TODO(), operation,
-			backoff.WithBackOff(backoff.NewExponentialBackOff()), backoff.WithMaxElapsedTime(maxElapsedTime))
 		if err != nil {
-			logger.Error(err, "Failed to update gcp regional backend service", "project", b.project, "region", region, "name", name)
-		} else {
-			logger.V(1).Info("Successfully updated gcp regional backend service", "project", b.project, "region", region, "name", name)
+			logOperationError(logger, err, "Compute operation failed", "operation", o.Name, "region", o.Region, "backendService", o.BackendService)
+			return nil, err
 		}
-		return err
+		logOperationWarnings(logger, op)
+		logger.V(1).Info("Compute operation finished", "operation", o.Name, "region", o.Region, "backendService", o.BackendService)
 	}
+	return pending, nil
 }
 
 func checkOperation(op *compute.Operation) error {
 	switch op.Status {
 	case computeOperationStatusPending:
-		return errors.New("operation pending")
+		return errOperationPending
 	case computeOperationStatusRunning:
-		return errors.New("operation running")
+		return errOperationRunning
 	case computeOperationStatusDone:
 		if op.Error != nil {
 			// patch operation failed
-			return fmt.Errorf("operation %d failed", op.Id)
+			return newErrOperationFailed(op)
 		}
 		return nil
 	}
 	return fmt.Errorf("unknown operation state: %s", op.Status)
 }
 
+// logOperationError logs an error, including the details of failed compute operations
+func logOperationError(logger logr.Logger, err error, msg string, keysAndValues ...any) {
+	var opErr *errOperationFailed
+	if errors.As(err, &opErr) {
+		keysAndValues = append(keysAndValues, opErr.keysAndValues()...)
+	}
+	logger.Error(err, msg, keysAndValues...)
+}
+
+// logOperationWarnings logs the warnings of a successful compute operation
+func logOperationWarnings(logger logr.Logger, op *compute.Operation) {
+	for _, w := range op.Warnings {
+		logger.Info("Compute operation warning", "operation", op.Name, "code", w.Code, "message", w.Message)
+	}
+}
+
 // ReconcileBackends takes the actual and intended AutonegStatus
-// and attempts to apply the intended status or return an error
-func (b *ProdBackendController) ReconcileBackends(ctx context.Context, actual, intended AutonegStatus, deleting bool) (err error) {
+// and attempts to apply the intended status or return an error.
+// If compute operations are still in progress, an *errOperationsPending
+// is returned listing them. If a change exceeds the change budget, an
+// *errChangeBudgetExhausted is returned unless operations are pending.
+// While mutations are frozen, no backend service is changed and an
+// *errMutationsFrozen lists the ones which would have been.
+// During a freeze window only backends are removed, and an
+// *errChangesDeferred lists the backend services with deferred changes.
+// The backends found changed out-of-band are returned as drift, and are
+// corrected or left alone depending on the drift policy of the intended
+// status. If drained backends are stepped to
+// their capacity scaler gradually, an *errDrainInProgress is returned until
+// all of them reached it. Changes of protected backend services are only
+// patched if the intended status holds the hash of their plan, otherwise an
+// *errApprovalRequired holds the plan.
+func (b *ProdBackendController) ReconcileBackends(ctx context.Context, actual, intended AutonegStatus, deleting bool) (drift []BackendDrift, err error) {
 	logger := log.FromContext(ctx)
 
 	// Log the start of backend reconciliation
@@ -305,6 +557,43 @@ func (b *ProdBackendController) ReconcileBackends(ctx context.Context, actual, i
 
 	var forceCapacity = make(map[int]bool, 0)
 	var currentBackends []compute.Backend
+	var pending []AutonegOperation
+	defer func() {
+		var budgetErr *errChangeBudgetExhausted
+		if errors.As(err, &budgetErr) && len(pending) > 0 {
+			// Record the operations already started, the remaining
+			// changes follow once the budget allows them.
+			err = &errOperationsPending{Operations: pending}
+		}
+	}()
+	var draining, restoring int
+	var waitingForHealth bool
+	// frozen are the backend services whose changes were skipped
+	var frozen []string
+	// deferred are the backend services whose changes other than removals
+	// wait for the end of a freeze window
+	var deferred []string
+	// protected are the patches of protected backend services, which wait
+	// for the approval of their plan
+	var protected []protectedPatch
+	patch := func(name, region string, svc *compute.BackendService, before map[string]compute.Backend) error {
+		change := backendServiceChange(name, region, before, svc.Backends)
+		if isProtected(intended.protected, name) && change != nil {
+			protected = append(protected, protectedPatch{change: *change, svc: svc, forceCapacity: maps.Clone(forceCapacity)})
+			return nil
+		}
+		// Deleting a service is not queued behind the change budget.
+		if !deleting {
+			if err := b.spendChangeBudget(intended, region, name); err != nil {
+				return err
+			}
+		}
+		op, err := b.updateBackends(ctx, name, region, svc, forceCapacity, deleting)
+		if op != nil {
+			pending = append(pending, *op)
+		}
+		return err
+	}
 	// Iterate over each port that has backends to be removed.
 	for port, _removes := range removes {
 		// Iterate over each backend service to be removed.
@@ -332,19 +621,112 @@ func (b *ProdBackendController) ReconcileBackends(ctx context.Context, actual, i
 			for _, cb := range oldSvc.Backends {
 				currentBackends = append(currentBackends, *cb)
 			}
+			oldBackends := snapshotBackends(oldSvc)
 
 			var newSvc *compute.BackendService
 			upsert := upserts[port][idx]
 
 			// Check if the same port is in the upsert map and if upsert needs to happen on a different backend service.
+			newBackends := oldBackends
 			if upsert.name != "" && upsert.name != remove.name {
 				if newSvc, err = b.getBackendService(ctx, upsert.name, upsert.region); err != nil {
 					return
 				}
+				newBackends = snapshotBackends(newSvc)
 			} else {
 				newSvc = oldSvc
 			}
 
+			// Detect backends changed out-of-band, and leave them alone unless
+			// the drift policy enforces the configuration.
+			var removedUnknown bool
+			// During a freeze window only backends are removed, other
+			// changes and new backend service targets are deferred.
+			deferUpserts := intended.deferChanges && !deleting && upsert.name != ""
+			skip := map[string]bool{}
+			if !deleting && upsert.name != "" {
+				svcDrift := detectDrift(b.project, actual, intended, port, idx, newSvc)
+				drift = append(drift, svcDrift...)
+				for _, d := range svcDrift {
+					if intended.driftPolicy != "" && intended.driftPolicy != driftPolicyEnforce {
+						skip[d.Group] = true
+						continue
+					}
+					if deferUpserts {
+						skip[d.Group] = true
+						deferred = append(deferred, upsert.name)
+						continue
+					}
+					if d.Kind == driftUnknownBackend {
+						newSvc.Backends = slices.DeleteFunc(newSvc.Backends, func(be *compute.Backend) bool {
+							return be.Group == d.Group
+						})
+						removedUnknown = true
+					}
+				}
+			}
+
+			// Set the capacity scaler of backends of a split backend
+			// service to this cluster's share of the traffic.
+			var split bool
+			if share, ok := intended.trafficSplit[trafficSplitKey(upsert.region, upsert.name)]; ok && !deleting && upsert.name != "" {
+				var capacity float64
+				if capacity, err = b.splitCapacity(ctx, newSvc, upsert.backends, share); err != nil {
+					return
+				}
+				logger.V(1).Info("Splitting traffic", "service", upsert.name, "region", upsert.region, "share", share, "capacityScaler", capacity)
+				for i := range upsert.backends {
+					upsert.backends[i].CapacityScaler = capacity
+				}
+				split = true
+			}
+
+			// Drain the backends in drained zones and restore the ones
+			// which are no longer drained.
+			var drainSynced map[string]bool
+			if !deleting && upsert.name != "" {
+				var progress drainProgress
+				drainSynced, progress = applyDrain(actual, intended, newSvc, upsert.backends)
+				if intended.drainStep > 0 && len(progress.reducing) > 0 {
+					// Only shift more traffic away from drained backends
+					// while another backend, e.g. of another cluster, can
+					// take it.
+					drained := map[string]bool{}
+					for _, u := range upsert.backends {
+						drained[u.Group] = intended.isDrained(u.Group)
+					}
+					var others []string
+					for _, be := range newSvc.Backends {
+						if _, ok := progress.reducing[be.Group]; !ok && !drained[be.Group] && be.CapacityScaler > 0 {
+							others = append(others, be.Group)
+						}
+					}
+					var healthy bool
+					if healthy, err = b.hasHealthyBackends(ctx, upsert.name, upsert.region, others); err != nil {
+						return
+					}
+					if !healthy {
+						logger.Info("Holding drain until other backends are healthy", "service", upsert.name, "region", upsert.region)
+						holdDrain(upsert.backends, progress)
+						waitingForHealth = true
+					}
+				}
+				draining += progress.draining
+				restoring += progress.restoring
+			}
+			syncFor := func(group string) *AutonegSyncConfig {
+				sync := intended.BackendSyncConfig(port, idx, group)
+				// Settings of zone overrides removed since the last
+				// reconciliation are reset to the configuration.
+				if zc, ok := actual.AutonegConfig.BackendServices[port][idx].Zones[groupZone(group)]; ok {
+					sync = zc.ownOverrides(sync)
+				}
+				if split || drainSynced[group] {
+					return syncCapacity(sync)
+				}
+				return sync
+			}
+
 			// Remove backends that are in the list to be deleted for this port.
 			for _, d := range remove.backends {
 				// Remove only the requested backends and keep the rest.
@@ -361,21 +743,44 @@ func (b *ProdBackendController) ReconcileBackends(ctx context.Context, actual, i
 			// If a different service needs to be updated based on the upsert map entry for this port,
 			// then save the existing backend service and update the new service.
 			if svcUpdated && (deleting || upsert.name == "" || upsert.name != remove.name || len(upsert.backends) == 0) {
-				if err = b.updateBackends(ctx, remove.name, remove.region, oldSvc, forceCapacity, deleting); err != nil {
+				if deferUpserts && upsert.name != remove.name {
+					// Moving to a new backend service is deferred as a whole.
+					deferred = append(deferred, upsert.name)
+				} else if intended.frozen {
+					frozen = append(frozen, remove.name)
+				} else if err = patch(remove.name, remove.region, oldSvc, oldBackends); err != nil {
 					return
 				}
 			}
 
 			// Add or update any new backends to the list
 			for _, u := range upsert.backends {
+				if skip[u.Group] || deferUpserts {
+					continue
+				}
 				copy := true
 				for _, be := range newSvc.Backends {
 					if u.Group == be.Group {
-						// TODO: copy fields explicitly
-						be.BalancingMode = u.BalancingMode
-						be.MaxRatePerEndpoint = u.MaxRatePerEndpoint
-						be.MaxConnectionsPerEndpoint = u.MaxConnectionsPerEndpoint
-						if len(u.CustomMetrics) > 0 {
+						// Only update the fields owned by autoneg
+						sync := syncFor(u.Group)
+						if sync.Owns(syncFieldBalancingMode) {
+							be.BalancingMode = u.BalancingMode
+						}
+						if sync.Owns(syncFieldRates) {
+							be.MaxRatePerEndpoint = u.MaxRatePerEndpoint
+							be.MaxConnectionsPerEndpoint = u.MaxConnectionsPerEndpoint
+							be.MaxRate = u.MaxRate
+							be.MaxConnections = u.MaxConnections
+						}
+						if sync.Owns(syncFieldFailover) {
+							be.Failover = u.Failover
+						}
+						if sync.Owns(syncFieldDescription) {
+							be.Description = u.Description
+						}
+						if !sync.Owns(syncFieldCustomMetrics) {
+							// Keep the custom metrics of the backend service
+						} else if len(u.CustomMetrics) > 0 {
 							// deep copy it
 							be.CustomMetrics = slices.Collect(func(yield func(*compute.BackendCustomMetric) bool) {
 								for bcm := range slices.Values(u.CustomMetrics) {
@@ -393,14 +798,8 @@ func (b *ProdBackendController) ReconcileBackends(ctx context.Context, actual, i
 							be.CustomMetrics = nil
 						}
 
-						if intended.AutonegSyncConfig != nil {
-							var syncConfig = *intended.AutonegSyncConfig
-							if syncConfig.CapacityScaler != nil && *syncConfig.CapacityScaler {
-								be.CapacityScaler = u.CapacityScaler
-							}
-						} else {
-							// Force CapacityScaler to an "empty value"
-							u.CapacityScaler = 0
+						if sync.Owns(syncFieldCapacityScaler) {
+							be.CapacityScaler = u.CapacityScaler
 						}
 						copy = false
 						break
@@ -427,9 +826,12 @@ func (b *ProdBackendController) ReconcileBackends(ctx context.Context, actual, i
 				// do nothing.
 				allMatch := true
 				for _, be := range upsert.backends {
+					if skip[be.Group] {
+						continue
+					}
 					found := false
 					for _, obe := range currentBackends {
-						if b.compareBackends(obe, be) {
+						if b.compareBackends(obe, be, syncFor(be.Group)) {
 							found = true
 							break
 						}
@@ -439,19 +841,80 @@ func (b *ProdBackendController) ReconcileBackends(ctx context.Context, actual, i
 						break
 					}
 				}
-				if !allMatch || deleting {
+				changed := !allMatch || removedUnknown
+				if deferUpserts && changed {
+					deferred = append(deferred, upsert.name)
+					changed = false
+				}
+				// Removed backends are patched even if the remaining ones
+				// match.
+				changed = changed || deleting || (svcUpdated && upsert.name == remove.name)
+				if changed && intended.frozen {
+					frozen = append(frozen, upsert.name)
+				} else if changed {
 					logger.Info("Updating backends for service", "service", newSvc, "deleting", deleting)
-					err = b.updateBackends(ctx, upsert.name, upsert.region, newSvc, forceCapacity, deleting)
+					err = patch(upsert.name, upsert.region, newSvc, newBackends)
 				}
 			}
 			if err != nil {
-				return err
+				return
+			}
+		}
+	}
+
+	if len(protected) > 0 {
+		changes := make([]BackendServiceChange, 0, len(protected))
+		for _, p := range protected {
+			changes = append(changes, p.change)
+		}
+		plan := newChangePlan(changes)
+		if plan.Hash != intended.approvedPlan {
+			logger.V(1).Info("Changes of protected backend services wait for approval", "hash", plan.Hash, "backendServices", plan.backendServices())
+			if len(pending) > 0 {
+				// Record the operations already started, the plan is
+				// made again once they finished.
+				return drift, &errOperationsPending{Operations: pending}
+			}
+			return drift, &errApprovalRequired{Plan: plan}
+		}
+		logger.Info("Applying approved plan", "hash", plan.Hash)
+		for _, p := range protected {
+			if !deleting {
+				if err = b.spendChangeBudget(intended, p.change.Region, p.change.Name); err != nil {
+					return
+				}
+			}
+			var op *AutonegOperation
+			op, err = b.updateBackends(ctx, p.change.Name, p.change.Region, p.svc, p.forceCapacity, deleting)
+			if op != nil {
+				pending = append(pending, *op)
+			}
+			if err != nil {
+				return
 			}
 		}
 	}
 
+	if len(frozen) > 0 {
+		logger.Info("Backend service changes skipped, mutations are frozen", "backendServices", frozen)
+		return drift, &errMutationsFrozen{BackendServices: frozen}
+	}
+	if len(pending) > 0 {
+		logger.V(1).Info("Backend reconciliation waiting for compute operations", "project", b.project, "operations", len(pending))
+		return drift, &errOperationsPending{Operations: pending}
+	}
+	if len(deferred) > 0 {
+		slices.Sort(deferred)
+		deferred = slices.Compact(deferred)
+		logger.Info("Backend service changes deferred by a freeze window", "backendServices", deferred)
+		return drift, &errChangesDeferred{BackendServices: deferred}
+	}
+	if draining > 0 || restoring > 0 || waitingForHealth {
+		logger.V(1).Info("Backend reconciliation stepping drain", "project", b.project, "draining", draining, "restoring", restoring, "waitingForHealth", waitingForHealth)
+		return drift, &errDrainInProgress{Draining: draining, Restoring: restoring, WaitingForHealth: waitingForHealth}
+	}
 	logger.V(1).Info("Completed backend reconciliation process", "project", b.project)
-	return nil
+	return drift, nil
 }
 
 // for sorting the backends to keep tests happy
@@ -504,6 +967,9 @@ func ReconcileStatus(logger logr.Logger, project string, actual AutonegStatus, i
 			sort.Strings(groupsKeys)
 
 			for _, i := range groupsKeys {
+				if !intended.attached(bname, port, i) {
+					continue
+				}
 				be := intended.Backend(bname, port, i)
 				upsert.backends = append(upsert.backends, be)
 			}
@@ -516,7 +982,7 @@ func ReconcileStatus(logger logr.Logger, project string, actual AutonegStatus, i
 				if actual.BackendServices[port][bname].Name == be.Name || actual.BackendServices[port][bname].Name == "" {
 					// find backends to be deleted
 					for a := range actualBE[port] {
-						if _, ok := intendedBE[port][a]; !ok {
+						if _, ok := intendedBE[port][a]; !ok || !intended.attached(bname, port, a) {
 							rbe := actual.Backend(bname, port, a)
 							remove.backends = append(remove.backends, rbe)
 						}
@@ -603,6 +1069,28 @@ func validateConfig(config AutonegConfig) error {
 				}
 			}
 
+			for zone, zc := range cfg.Zones {
+				if zc.CapacityScaler != nil && (*zc.CapacityScaler < 0 || *zc.CapacityScaler > 100) {
+					return fmt.Errorf("capacity_scaler of zone %q for backend %q must be between 0 and 100 inclusive, but was %d; see https://cloud.google.com/load-balancing/docs/backend-service#capacity_scaler for details", zone, cfg.Name, *zc.CapacityScaler)
+				}
+				if zc.Rate != nil && *zc.Rate <= 0 {
+					return fmt.Errorf("max_rate_per_endpoint of zone %q for backend %q must be positive, but was %g; use capacity_scaler 0 to drain the zone", zone, cfg.Name, *zc.Rate)
+				}
+				if zc.Connections != nil && *zc.Connections <= 0 {
+					return fmt.Errorf("max_connections_per_endpoint of zone %q for backend %q must be positive, but was %g; use capacity_scaler 0 to drain the zone", zone, cfg.Name, *zc.Connections)
+				}
+				if zc.Rate != nil && (len(cfg.CustomMetrics) > 0 || cfg.Rate <= 0) {
+					return fmt.Errorf("max_rate_per_endpoint of zone %q for backend %q requires the RATE balancing mode, i.e. max_rate_per_endpoint for the backend", zone, cfg.Name)
+				}
+				if zc.Connections != nil && (len(cfg.CustomMetrics) > 0 || cfg.Rate > 0) {
+					return fmt.Errorf("max_connections_per_endpoint of zone %q for backend %q requires the CONNECTION balancing mode, i.e. max_connections_per_endpoint without max_rate_per_endpoint for the backend", zone, cfg.Name)
+				}
+			}
+
+			if cfg.WeightByEndpoints && len(cfg.CustomMetrics) > 0 {
+				return fmt.Errorf("weight_by_endpoints for backend %q requires max_rate_per_endpoint or max_connections_per_endpoint, not custom_metrics", cfg.Name)
+			}
+
 			if len(cfg.CustomMetrics) > 0 {
 				if len(cfg.CustomMetrics) > 3 {
 					return fmt.Errorf("too many custom_metrics for backend %q must be at most 3, but was %q; see https://docs.cloud.google.com/load-balancing/docs/https/applb-custom-metrics#metrics-limits-requirements for details", cfg.Name, len(cfg.CustomMetrics))
@@ -655,28 +1143,29 @@ func getStatuses(ctx context.Context, namespace string, name string, annotations
 			}
 		}
 
-		s.config.BackendServices = make(map[string]map[string]AutonegNEGConfig, len(tempConfig.BackendServices))
-		for port, cfgs := range tempConfig.BackendServices {
-			s.config.BackendServices[port] = make(map[string]AutonegNEGConfig, len(cfgs))
-			for _, cfg := range cfgs {
-				if cfg.Name == "" || !r.AllowServiceName {
-					// Default to name generated using serviceNameTemplate
-					cfg.Name = generateServiceName(namespace, name, port, r.ServiceNameTemplate)
-				}
-
-				//Use defaults if rate and connections have not been set
-				if cfg.Rate == 0 && cfg.Connections == 0 {
-					if r.MaxRatePerEndpointDefault > 0 {
-						cfg.Rate = StringOrFloat(r.MaxRatePerEndpointDefault)
-					} else {
-						cfg.Connections = StringOrFloat(r.MaxConnectionsPerEndpointDefault)
-					}
-				}
+		s.driftPolicy = driftPolicyEnforce
+		if policy, ok := annotations[autonegDriftPolicyAnnotation]; ok {
+			if err = validateDriftPolicy(policy); err != nil {
+				return
+			}
+			s.driftPolicy = policy
+		}
 
-				s.config.BackendServices[port][cfg.Name] = cfg
+		s.maxBackendRemoval = r.MaxBackendRemovalPercent
+		if percent, ok := annotations[autonegMaxBackendRemovalAnnotation]; ok {
+			if s.maxBackendRemoval, err = parseMaxBackendRemoval(percent); err != nil {
+				return
+			}
+		}
+		_, s.allowBackendRemoval = annotations[autonegAllowBackendRemovalAnnotation]
+		if priority, ok := annotations[autonegChangePriorityAnnotation]; ok {
+			if s.changePriority, err = parseChangePriority(priority); err != nil {
+				return
 			}
 		}
 
+		s.config = r.autonegConfig(namespace, name, tempConfig)
+
 		// Is this autoneg config valid?
 		if err = validateConfig(s.config); err != nil {
 			return
@@ -704,26 +1193,86 @@ func getStatuses(ctx context.Context, namespace string, name string, annotations
 		if err = json.Unmarshal([]byte(tmp), &s.negStatus); err != nil {
 			return
 		}
-		// Check if we should use ServiceNetworkEndpointGroup custom resource to get the NEG zones.
-		if r.UseSvcNeg {
+		// Check if we should use ServiceNetworkEndpointGroup custom resource
+		// or EndpointSlices to get the NEG zones.
+		var zones []string
+		switch source := r.zoneSource(); source {
+		case ZoneSourceSvcNeg:
 			logger.Info("Getting zones using svcneg custom resources")
-			var zones []string
-			zones, err = zonesFromSvcNeg(ctx, r, namespace, &s.negStatus)
+			zones, s.missingNEGs, err = zonesFromSvcNeg(ctx, r, namespace, &s.negStatus)
 			if err != nil {
 				return
 			}
+			if len(s.missingNEGs) > 0 && r.ProtectMissingSvcNeg {
+				// The zones of the missing NEGs are unknown, so keep the
+				// reconciled zones instead of removing their backends.
+				logger.Info("Keeping previous zones while svcnegs are missing", "negs", s.missingNEGs, "zones", s.status.Zones)
+				s.negStatus.Zones = s.status.Zones
+				break
+			}
 			// Update the zones.
 			logger.Info("Got zones from svcnegs", "zones", zones)
+			checkZones(logger, source, zones, s.negStatus.Zones)
+			s.negStatus.Zones = zones
+		case ZoneSourceEndpointSlices:
+			zones, err = zonesFromEndpointSlices(ctx, r, namespace, name)
+			if err != nil {
+				return
+			}
+			logger.V(1).Info("Got zones from endpointslices", "zones", zones)
+			checkZones(logger, source, zones, s.negStatus.Zones)
+			if len(zones) == 0 && len(s.negStatus.NEGs) > 0 {
+				// The service scaled to zero or its endpointslices are
+				// briefly missing, keep the reconciled zones instead of
+				// removing all backends.
+				logger.Info("Keeping previous zones while endpointslices have no zones", "zones", s.status.Zones)
+				s.negStatus.Zones = s.status.Zones
+				break
+			}
 			s.negStatus.Zones = zones
 		}
 	}
 
+	// May the namespace use the backend services? This is checked last, so
+	// the statuses of a denied service being deleted are complete.
+	if newOk && r.NamespacePolicy {
+		err = r.authorizeBackendServices(ctx, namespace, s.config)
+	}
+	return
+}
+
+// autonegConfig resolves the backend service names and default rates of the
+// autoneg annotation of a service
+func (r *ServiceReconciler) autonegConfig(namespace string, name string, tempConfig AutonegConfigTemp) (config AutonegConfig) {
+	config.BackendServices = make(map[string]map[string]AutonegNEGConfig, len(tempConfig.BackendServices))
+	for port, cfgs := range tempConfig.BackendServices {
+		config.BackendServices[port] = make(map[string]AutonegNEGConfig, len(cfgs))
+		for _, cfg := range cfgs {
+			if cfg.Name == "" || !r.AllowServiceName {
+				// Default to name generated using serviceNameTemplate
+				cfg.Name = generateServiceName(namespace, name, port, r.ServiceNameTemplate)
+			}
+
+			//Use defaults if rate and connections have not been set
+			if cfg.Rate == 0 && cfg.Connections == 0 {
+				if r.MaxRatePerEndpointDefault > 0 {
+					cfg.Rate = StringOrFloat(r.MaxRatePerEndpointDefault)
+				} else {
+					cfg.Connections = StringOrFloat(r.MaxConnectionsPerEndpointDefault)
+				}
+			}
+
+			config.BackendServices[port][cfg.Name] = cfg
+		}
+	}
 	return
 }
 
-func zonesFromSvcNeg(ctx context.Context, reader client.Reader, namespace string, negStatus *NEGStatus) ([]string, error) {
+// zonesFromSvcNeg returns the zones of the svcneg objects of the NEGs, and
+// the NEGs which have no svcneg object.
+func zonesFromSvcNeg(ctx context.Context, reader client.Reader, namespace string, negStatus *NEGStatus) (zones []string, missing []string, err error) {
 	logger := log.FromContext(ctx)
-	zones := []string{}
+	zones = []string{}
 	negsProcessed := map[string]bool{}
 	for _, neg := range negStatus.NEGs {
 		if _, ok := negsProcessed[neg]; ok {
@@ -736,10 +1285,11 @@ func zonesFromSvcNeg(ctx context.Context, reader client.Reader, namespace string
 		}, &svcNeg)
 		if apierrors.IsNotFound(err) {
 			logger.Info("SvcNeg not found", "neg", neg)
+			missing = append(missing, neg)
 			continue
 		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to get svcneg %s: %w", neg, err)
+			return nil, nil, fmt.Errorf("failed to get svcneg %s: %w", neg, err)
 		}
 		for _, negRef := range svcNeg.Status.NetworkEndpointGroups {
 			negZone := zone(negRef)
@@ -748,7 +1298,8 @@ func zonesFromSvcNeg(ctx context.Context, reader client.Reader, namespace string
 			}
 		}
 	}
-	return zones, nil
+	slices.Sort(missing)
+	return zones, missing, nil
 }
 
 func zone(ref v1beta1.NegObjectReference) string {
@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"maps"
 	"math"
 	"net/http"
@@ -1057,6 +1058,44 @@ func Test_checkOperation(t *testing.T) {
 	}
 }
 
+func Test_checkOperationErrorDetails(t *testing.T) {
+	tests := []struct {
+		code      string
+		class     string
+		retryable bool
+	}{
+		{"QUOTA_EXCEEDED", errorClassQuotaExceeded, true},
+		{"RESOURCE_IN_USE_BY_ANOTHER_RESOURCE", errorClassResourceInUse, true},
+		{"INVALID_FIELD_VALUE", errorClassInvalidField, false},
+		{"PERMISSIONS_ERROR", errorClassPermissionDenied, true},
+		{"SOMETHING_ELSE", errorClassUnknown, true},
+	}
+	for _, tt := range tests {
+		err := checkOperation(&compute.Operation{
+			Name:   "operation-1",
+			Status: computeOperationStatusDone,
+			Error: &compute.OperationError{Errors: []*compute.OperationErrorErrors{
+				{Code: tt.code, Message: "something went wrong", Location: "backends[0].group"},
+			}},
+			Warnings: []*compute.OperationWarnings{{Code: "DEPRECATED", Message: "careful"}},
+		})
+		var opErr *errOperationFailed
+		if !errors.As(err, &opErr) {
+			t.Fatalf("%s: checkOperation() got %v, want *errOperationFailed", tt.code, err)
+		}
+		want := "operation operation-1 failed: " + tt.code + ": something went wrong (location: backends[0].group); warning DEPRECATED: careful"
+		if err.Error() != want {
+			t.Errorf("%s: Error() = %q, want %q", tt.code, err.Error(), want)
+		}
+		if opErr.Class() != tt.class {
+			t.Errorf("%s: Class() = %q, want %q", tt.code, opErr.Class(), tt.class)
+		}
+		if isRetryable(err) != tt.retryable {
+			t.Errorf("%s: isRetryable() = %v, want %v", tt.code, isRetryable(err), tt.retryable)
+		}
+	}
+}
+
 func TestReconcileBackendsDeletionWithMissingBackend(t *testing.T) {
 	s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
 		t.Logf("Got request: %s", req.URL.String())
@@ -1071,7 +1110,7 @@ func TestReconcileBackendsDeletionWithMissingBackend(t *testing.T) {
 		project: "test-project",
 		s:       cs,
 	}
-	err = bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, AutonegStatus{
+	_, err = bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, AutonegStatus{
 		// On deletion, the intended state is set to empty.
 		AutonegConfig: AutonegConfig{},
 		NEGStatus:     negStatus,
@@ -1095,7 +1134,7 @@ func TestReconcileBackendsDeletionWithEmptyNEGStatus(t *testing.T) {
 		project: "test-project",
 		s:       cs,
 	}
-	err = bc.ReconcileBackends(context.Background(), AutonegStatus{
+	_, err = bc.ReconcileBackends(context.Background(), AutonegStatus{
 		AutonegConfig: AutonegConfig{
 			BackendServices: map[string]map[string]AutonegNEGConfig{
 				"80": {
@@ -1158,7 +1197,7 @@ func TestReconcileBackendsRemovesOrphanedBackends(t *testing.T) {
 	bc := ProdBackendController{project: "test-project", s: cs}
 	negStatus := NEGStatus{NEGs: map[string]string{"80": "neg1"}, Zones: []string{"zone1"}}
 
-	err = bc.ReconcileBackends(context.Background(),
+	_, err = bc.ReconcileBackends(context.Background(),
 		AutonegStatus{
 			AutonegConfig: AutonegConfig{BackendServices: map[string]map[string]AutonegNEGConfig{"80": {"http-be": {Name: "http-be", Rate: 100}}}},
 			NEGStatus:     negStatus,
@@ -1170,8 +1209,17 @@ func TestReconcileBackendsRemovesOrphanedBackends(t *testing.T) {
 		false,
 	)
 
-	if err != nil {
-		t.Errorf("ReconcileBackends() got err: %v, want none", err)
+	// The patch operation is still running, ReconcileBackends returns it.
+	var pendingErr *errOperationsPending
+	if !errors.As(err, &pendingErr) {
+		t.Fatalf("ReconcileBackends() got err: %v, want pending operations", err)
+	}
+	if len(pendingErr.Operations) != 1 || pendingErr.Operations[0].Name != "op-123" {
+		t.Errorf("ReconcileBackends() got pending operations %+v, want op-123", pendingErr.Operations)
+	}
+	pending, err := bc.CheckOperations(context.Background(), pendingErr.Operations)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("CheckOperations() got %+v, %v, want no pending operations", pending, err)
 	}
 	if !updateCalled {
 		t.Errorf("ReconcileBackends() did not trigger a GCP Update API call.")
@@ -1221,7 +1269,7 @@ func TestReconcileBackendsWithCustomMetricsAgainstFakeServer(t *testing.T) {
 			ForceSendFields: []string{"Backends"},
 			Backends:        []*compute.Backend{&ab},
 		}},
-		map[string][][2]string{"fake": {{"GET", "backendServices"}, {"PATCH", "backendServices"}, {"GET", "operations"}, {"GET", "operations"}}},
+		map[string][][2]string{"fake": {{"GET", "backendServices"}, {"PATCH", "backendServices"}, {"GET", "operations"}}},
 		map[string][]string{"fake": {computeOperationStatusPending, computeOperationStatusDone}},
 		t)
 
@@ -1234,9 +1282,14 @@ func TestReconcileBackendsWithCustomMetricsAgainstFakeServer(t *testing.T) {
 		s:       cs,
 	}
 
-	err = bc.ReconcileBackends(context.Background(), as, is, false)
-	if err != nil {
-		t.Errorf("ReconcileBackends() got err: %v, want none", err)
+	_, err = bc.ReconcileBackends(context.Background(), as, is, false)
+	var pendingErr *errOperationsPending
+	if !errors.As(err, &pendingErr) {
+		t.Fatalf("ReconcileBackends() got err: %v, want pending operations", err)
+	}
+	pending, err := bc.CheckOperations(context.Background(), pendingErr.Operations)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("CheckOperations() got %+v, %v, want no pending operations", pending, err)
 	}
 
 	if checkExpectedCallsAreDone {
@@ -1272,6 +1325,7 @@ func TestZonesFromSvcNeg(t *testing.T) {
 		svcNeg       *v1beta1.ServiceNetworkEndpointGroup
 		getSvcNegErr error
 		wantZones    []string
+		wantMissing  []string
 		wantErr      bool
 	}{
 		{
@@ -1300,8 +1354,9 @@ func TestZonesFromSvcNeg(t *testing.T) {
 			negStatus: &NEGStatus{
 				NEGs: map[string]string{"80": fakeNeg},
 			},
-			wantZones: []string{},
-			wantErr:   false,
+			wantZones:   []string{},
+			wantMissing: []string{fakeNeg},
+			wantErr:     false,
 		},
 		{
 			name:         "get svcneg error",
@@ -1319,13 +1374,287 @@ func TestZonesFromSvcNeg(t *testing.T) {
 				svcNeg: tt.svcNeg,
 				getErr: tt.getSvcNegErr,
 			}
-			zones, err := zonesFromSvcNeg(context.Background(), r, "test", tt.negStatus)
+			zones, missing, err := zonesFromSvcNeg(context.Background(), r, "test", tt.negStatus)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ZonesFromSvcNeg() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if !reflect.DeepEqual(zones, tt.wantZones) {
 				t.Errorf("ZonesFromSvcNeg() zones = %v, want %v", zones, tt.wantZones)
 			}
+			if !reflect.DeepEqual(missing, tt.wantMissing) {
+				t.Errorf("ZonesFromSvcNeg() missing = %v, want %v", missing, tt.wantMissing)
+			}
 		})
 	}
 }
+
+func TestSyncConfigOwns(t *testing.T) {
+	var nilConfig *AutonegSyncConfig
+	owned := map[string]bool{
+		syncFieldBalancingMode:  true,
+		syncFieldRates:          true,
+		syncFieldCustomMetrics:  true,
+		syncFieldCapacityScaler: false,
+		syncFieldFailover:       false,
+		syncFieldDescription:    false,
+	}
+	for field, want := range owned {
+		if got := nilConfig.Owns(field); got != want {
+			t.Errorf("nil Owns(%q) = %v, want %v", field, got, want)
+		}
+		if got := (&AutonegSyncConfig{}).Owns(field); got != want {
+			t.Errorf("empty Owns(%q) = %v, want %v", field, got, want)
+		}
+	}
+
+	var sync AutonegSyncConfig
+	if err := json.Unmarshal([]byte(`{"custom_metrics":false,"capacity_scaler":true,"description":true}`), &sync); err != nil {
+		t.Fatalf("json.Unmarshal() got err: %v", err)
+	}
+	owned[syncFieldCustomMetrics] = false
+	owned[syncFieldCapacityScaler] = true
+	owned[syncFieldDescription] = true
+	for field, want := range owned {
+		if got := sync.Owns(field); got != want {
+			t.Errorf("Owns(%q) = %v, want %v", field, got, want)
+		}
+	}
+}
+
+func TestCompareBackendsOwnership(t *testing.T) {
+	base := compute.Backend{
+		Group:              "group",
+		BalancingMode:      "CUSTOM_METRICS",
+		MaxRatePerEndpoint: 100,
+		CapacityScaler:     1,
+		CustomMetrics:      []*compute.BackendCustomMetric{{Name: "metric", MaxUtilization: 0.8}},
+	}
+	changed := base
+	changed.CapacityScaler = 0.5
+	changed.Description = "changed out-of-band"
+	changed.CustomMetrics = []*compute.BackendCustomMetric{{Name: "metric", MaxUtilization: 0.9}}
+
+	bc := &ProdBackendController{}
+	if !bc.compareBackends(base, base, nil) {
+		t.Errorf("compareBackends() of equal backends = false, want true")
+	}
+	if bc.compareBackends(base, changed, nil) {
+		t.Errorf("compareBackends() with changed custom metrics = true, want false")
+	}
+	notOwned := &AutonegSyncConfig{CustomMetrics: ptr.To(false)}
+	if !bc.compareBackends(base, changed, notOwned) {
+		t.Errorf("compareBackends() with changes to fields not owned = false, want true")
+	}
+	owned := &AutonegSyncConfig{CustomMetrics: ptr.To(false), Description: ptr.To(true)}
+	if bc.compareBackends(base, changed, owned) {
+		t.Errorf("compareBackends() with changed owned description = true, want false")
+	}
+}
+
+func TestReconcileBackendsKeepsFieldsNotOwned(t *testing.T) {
+	for _, owned := range []bool{true, false} {
+		var patched *compute.BackendService
+		s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			res.Header().Set("Content-Type", "application/json")
+			if req.Method == http.MethodPatch {
+				patched = &compute.BackendService{}
+				json.NewDecoder(req.Body).Decode(patched)
+				json.NewEncoder(res).Encode(compute.Operation{Name: "op", Status: computeOperationStatusDone})
+				return
+			}
+			// The custom metric thresholds were adjusted out-of-band.
+			var backends []*compute.Backend
+			for _, zone := range negStatus.Zones {
+				be := statusCMWithNEGs.Backend("test", "80", getGroup(fakeProject, zone, fakeNeg))
+				be.CustomMetrics[0].MaxUtilization = 0.95
+				backends = append(backends, &be)
+			}
+			json.NewEncoder(res).Encode(compute.BackendService{Name: "test", Backends: backends})
+		}))
+
+		cs, err := compute.NewService(context.Background(), option.WithEndpoint(s.URL), option.WithoutAuthentication())
+		if err != nil {
+			t.Fatalf("Failed to instantiate compute service: %v", err)
+		}
+		bc := ProdBackendController{project: fakeProject, s: cs}
+		status := statusCMWithNEGs
+		status.AutonegSyncConfig = &AutonegSyncConfig{CustomMetrics: ptr.To(owned)}
+		if _, err = bc.ReconcileBackends(context.Background(), status, status, false); err != nil {
+			t.Fatalf("ReconcileBackends() got err: %v", err)
+		}
+		s.Close()
+
+		if owned {
+			if patched == nil || patched.Backends[0].CustomMetrics[0].MaxUtilization != 0.8 {
+				t.Errorf("ReconcileBackends() with owned custom metrics patched %+v, want max utilization reverted to 0.8", patched)
+			}
+		} else if patched != nil {
+			t.Errorf("ReconcileBackends() with custom metrics not owned patched the backend service")
+		}
+	}
+}
+
+func TestPerBackendServiceSyncConfig(t *testing.T) {
+	annotations := map[string]string{
+		autonegAnnotation:     `{"backend_services":{"80":[{"name":"internal","max_rate_per_endpoint":100,"sync":{"capacity_scaler":true}},{"name":"external","max_rate_per_endpoint":100}],"443":[{"name":"tls","max_rate_per_endpoint":100,"sync":{"custom_metrics":false}}]}}`,
+		autonegSyncAnnotation: `{"rates":false}`,
+	}
+	r := &ServiceReconciler{AllowServiceName: true, ServiceNameTemplate: serviceNameTemplate}
+	s, valid, err := getStatuses(context.Background(), "ns", "test", annotations, r)
+	if err != nil || !valid {
+		t.Fatalf("getStatuses() got %v, %v, want valid config", valid, err)
+	}
+	status := AutonegStatus{AutonegConfig: s.config, AutonegSyncConfig: s.syncConfig}
+
+	tests := []struct {
+		port, name string
+		owned      map[string]bool
+	}{
+		{"80", "internal", map[string]bool{syncFieldCapacityScaler: true, syncFieldRates: false, syncFieldCustomMetrics: true}},
+		{"80", "external", map[string]bool{syncFieldCapacityScaler: false, syncFieldRates: false, syncFieldCustomMetrics: true}},
+		{"443", "tls", map[string]bool{syncFieldCapacityScaler: false, syncFieldRates: false, syncFieldCustomMetrics: false}},
+	}
+	for _, tt := range tests {
+		sync := status.SyncConfig(tt.port, tt.name)
+		for field, want := range tt.owned {
+			if got := sync.Owns(field); got != want {
+				t.Errorf("SyncConfig(%q, %q).Owns(%q) = %v, want %v", tt.port, tt.name, field, got, want)
+			}
+		}
+	}
+	if *status.AutonegSyncConfig.Rates || status.AutonegSyncConfig.CapacityScaler != nil {
+		t.Errorf("SyncConfig() modified the service sync configuration: %+v", status.AutonegSyncConfig)
+	}
+
+	// The per backend service settings are stored with the status.
+	data, err := json.Marshal(status)
+	if err != nil {
+		t.Fatalf("json.Marshal() got err: %v", err)
+	}
+	var decoded AutonegStatus
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() got err: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.SyncConfig("80", "internal"), status.SyncConfig("80", "internal")) {
+		t.Errorf("decoded status has sync config %+v, want %+v", decoded.SyncConfig("80", "internal"), status.SyncConfig("80", "internal"))
+	}
+}
+
+func TestBackendZoneOverrides(t *testing.T) {
+	var cfg AutonegConfigTemp
+	if err := json.Unmarshal([]byte(`{"backend_services":{"80":[{"name":"test","max_rate_per_endpoint":100,"initial_capacity":50,"zones":{"zone1":{"capacity_scaler":"0"},"zone2":{"max_rate_per_endpoint":"20"}}}]}}`), &cfg); err != nil {
+		t.Fatalf("json.Unmarshal() got err: %v", err)
+	}
+	status := AutonegStatus{
+		AutonegConfig: AutonegConfig{BackendServices: map[string]map[string]AutonegNEGConfig{"80": {"test": cfg.BackendServices["80"][0]}}},
+		NEGStatus:     NEGStatus{NEGs: map[string]string{"80": fakeNeg}, Zones: []string{"zone1", "zone2", "zone3"}},
+	}
+	if err := validateConfig(status.AutonegConfig); err != nil {
+		t.Fatalf("validateConfig() got err: %v", err)
+	}
+
+	tests := []struct {
+		zone         string
+		wantCapacity float64
+		wantRate     float64
+		wantSync     map[string]bool
+	}{
+		{"zone1", 0, 100, map[string]bool{syncFieldCapacityScaler: true, syncFieldRates: true}},
+		{"zone2", 0.5, 20, map[string]bool{syncFieldCapacityScaler: false, syncFieldRates: true}},
+		{"zone3", 0.5, 100, map[string]bool{syncFieldCapacityScaler: false, syncFieldRates: true}},
+	}
+	for _, tt := range tests {
+		group := getGroup(fakeProject, tt.zone, fakeNeg)
+		be := status.Backend("test", "80", group)
+		if be.CapacityScaler != tt.wantCapacity || be.MaxRatePerEndpoint != tt.wantRate || be.BalancingMode != "RATE" {
+			t.Errorf("Backend() in %s = %+v, want capacity %g and rate %g", tt.zone, be, tt.wantCapacity, tt.wantRate)
+		}
+		sync := status.BackendSyncConfig("80", "test", group)
+		for field, want := range tt.wantSync {
+			if got := sync.Owns(field); got != want {
+				t.Errorf("BackendSyncConfig() in %s owns %s = %v, want %v", tt.zone, field, got, want)
+			}
+		}
+	}
+
+	// Overrides keep the balancing mode of the backend service.
+	connections := AutonegNEGConfig{Name: "test", Connections: 10, Zones: map[string]AutonegZoneConfig{"zone1": {Rate: ptr.To(StringOrFloat(20))}, "zone2": {Connections: ptr.To(StringOrFloat(5))}}}
+	status.BackendServices["80"]["test"] = connections
+	be := status.Backend("test", "80", getGroup(fakeProject, "zone2", fakeNeg))
+	if be.BalancingMode != "CONNECTION" || be.MaxConnectionsPerEndpoint != 5 {
+		t.Errorf("Backend() in zone2 = %+v, want 5 connections", be)
+	}
+	be = status.Backend("test", "80", getGroup(fakeProject, "zone1", fakeNeg))
+	if be.BalancingMode != "CONNECTION" || be.MaxConnectionsPerEndpoint != 10 || be.MaxRatePerEndpoint != 0 {
+		t.Errorf("Backend() in zone1 = %+v, want 10 connections", be)
+	}
+
+	invalid := map[string]AutonegNEGConfig{
+		"zone capacity_scaler 101":   {Name: "test", Rate: 100, Zones: map[string]AutonegZoneConfig{"zone1": {CapacityScaler: ptr.To(StringOrInt(101))}}},
+		"zone rate 0":                {Name: "test", Rate: 100, Zones: map[string]AutonegZoneConfig{"zone1": {Rate: ptr.To(StringOrFloat(0))}}},
+		"zone connections 0":         {Name: "test", Connections: 10, Zones: map[string]AutonegZoneConfig{"zone1": {Connections: ptr.To(StringOrFloat(0))}}},
+		"zone rate with connections": connections,
+		"zone connections with rate": {Name: "test", Rate: 100, Zones: map[string]AutonegZoneConfig{"zone1": {Connections: ptr.To(StringOrFloat(5))}}},
+	}
+	for name, neg := range invalid {
+		status.BackendServices["80"]["test"] = neg
+		if err := validateConfig(status.AutonegConfig); err == nil {
+			t.Errorf("validateConfig() with %s got no error", name)
+		}
+	}
+}
+
+func TestReconcileBackendsZoneOverrides(t *testing.T) {
+	zone1 := getGroup(fakeProject, "zone1", fakeNeg)
+	var current []*compute.Backend
+	var patched map[string]float64
+	s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if req.Method == http.MethodPatch {
+			var body compute.BackendService
+			json.NewDecoder(req.Body).Decode(&body)
+			patched = map[string]float64{}
+			for _, be := range body.Backends {
+				patched[be.Group] = be.CapacityScaler
+			}
+			json.NewEncoder(res).Encode(compute.Operation{Name: "op", Status: computeOperationStatusDone})
+			return
+		}
+		json.NewEncoder(res).Encode(compute.BackendService{Name: "test", Backends: current})
+	}))
+	defer s.Close()
+	cs, err := compute.NewService(context.Background(), option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to instantiate compute service: %v", err)
+	}
+	bc := ProdBackendController{project: fakeProject, s: cs}
+
+	overridden := statusBasicWithNEGs
+	cfg := configBasicPort80
+	cfg.Zones = map[string]AutonegZoneConfig{"zone1": {CapacityScaler: ptr.To(StringOrInt(0))}}
+	overridden.AutonegConfig = AutonegConfig{BackendServices: map[string]map[string]AutonegNEGConfig{"80": {"test": cfg}}}
+
+	// Adding the override drains the zone.
+	current = driftTestBackends(nil)
+	if _, err := bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, overridden, false); err != nil {
+		t.Fatalf("ReconcileBackends() got err: %v", err)
+	}
+	if patched[zone1] != 0 {
+		t.Errorf("ReconcileBackends() patched capacity scaler %g of zone1, want 0", patched[zone1])
+	}
+
+	// Removing it restores the capacity scaler of the configuration,
+	// although capacity_scaler is not owned.
+	current = driftTestBackends(func(zone string, be *compute.Backend) {
+		if zone == "zone1" {
+			be.CapacityScaler = 0
+		}
+	})
+	patched = nil
+	if _, err := bc.ReconcileBackends(context.Background(), overridden, statusBasicWithNEGs, false); err != nil {
+		t.Fatalf("ReconcileBackends() got err: %v", err)
+	}
+	if want := statusBasicWithNEGs.Backend("test", "80", zone1).CapacityScaler; patched == nil || patched[zone1] != want {
+		t.Errorf("ReconcileBackends() patched capacity scalers %v, want %g for zone1", patched, want)
+	}
+}
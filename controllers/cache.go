@@ -0,0 +1,242 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Results of backend service cache lookups, used as metric labels
+const (
+	cacheResultHit         = "hit"
+	cacheResultRevalidated = "revalidated"
+	cacheResultMiss        = "miss"
+)
+
+// BackendServiceCacheOptions configures the backend service cache of
+// ProdBackendController
+type BackendServiceCacheOptions struct {
+	// Enabled keeps the last backend service read from the compute API and
+	// revalidates it with a conditional request using its ETag.
+	Enabled bool
+	// RefreshInterval is the period of listing all backend services of
+	// the project. Cached backend services whose fingerprint matched one of
+	// the last two listings are used without any request. Zero disables the
+	// periodic refresh.
+	RefreshInterval time.Duration
+}
+
+// cachedBackendService is a backend service as last read from the compute API
+type cachedBackendService struct {
+	svc *compute.BackendService
+	// confirmed is the time the fingerprint was last confirmed by a listing
+	confirmed time.Time
+}
+
+// backendServiceCache holds backend services keyed by project, region and name
+type backendServiceCache struct {
+	sync.Mutex
+	refreshInterval time.Duration
+	entries         map[string]*cachedBackendService
+}
+
+func newBackendServiceCache(opts BackendServiceCacheOptions) *backendServiceCache {
+	if !opts.Enabled {
+		return nil
+	}
+	return &backendServiceCache{
+		refreshInterval: opts.RefreshInterval,
+		entries:         make(map[string]*cachedBackendService),
+	}
+}
+
+func backendServiceCacheKey(project, region, name string) string {
+	if region == "" {
+		region = "global"
+	}
+	return project + "/" + region + "/" + name
+}
+
+// copyBackendService returns a deep copy of the backend service including
+// the response headers, as callers modify the backends in place.
+func copyBackendService(svc *compute.BackendService) (*compute.BackendService, error) {
+	data, err := json.Marshal(svc)
+	if err != nil {
+		return nil, err
+	}
+	c := &compute.BackendService{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	c.ServerResponse = googleapi.ServerResponse{
+		HTTPStatusCode: svc.ServerResponse.HTTPStatusCode,
+		Header:         svc.Header.Clone(),
+	}
+	return c, nil
+}
+
+// get returns a copy of the cached backend service and whether it was
+// confirmed by a listing recently enough to be used without revalidation.
+func (c *backendServiceCache) get(key string) (*compute.BackendService, bool) {
+	c.Lock()
+	defer c.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	svc, err := copyBackendService(entry.svc)
+	if err != nil {
+		return nil, false
+	}
+	fresh := c.refreshInterval > 0 && time.Since(entry.confirmed) < 2*c.refreshInterval
+	return svc, fresh
+}
+
+func (c *backendServiceCache) put(key string, svc *compute.BackendService) {
+	stored, err := copyBackendService(svc)
+	if err != nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.entries[key] = &cachedBackendService{svc: stored}
+}
+
+func (c *backendServiceCache) invalidate(key string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.entries, key)
+}
+
+// confirm marks the cached backend services whose fingerprint matches the
+// listed one as fresh, and drops the ones which changed or disappeared.
+func (c *backendServiceCache) confirm(fingerprints map[string]string, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	for key, entry := range c.entries {
+		if fp, ok := fingerprints[key]; ok && fp == entry.svc.Fingerprint {
+			entry.confirmed = now
+		} else {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// cachedBackendService returns the backend service from the cache, either
+// directly if a listing recently confirmed it, or after revalidating it with
+// its ETag. The returned bool is false if the backend service has to be read.
+func (b *ProdBackendController) cachedBackendService(ctx context.Context, key string, get func(etag string) (*compute.BackendService, error)) (*compute.BackendService, bool, error) {
+	cached, fresh := b.cache.get(key)
+	if cached == nil {
+		b.observeCache(cacheResultMiss)
+		return nil, false, nil
+	}
+	if fresh {
+		b.observeCache(cacheResultHit)
+		return cached, true, nil
+	}
+	etag := cached.Header.Get("ETag")
+	if etag == "" {
+		b.observeCache(cacheResultMiss)
+		return nil, false, nil
+	}
+	svc, err := get(etag)
+	if googleapi.IsNotModified(err) {
+		log.FromContext(ctx).V(1).Info("Cached gcp backend service is up to date", "key", key)
+		b.observeCache(cacheResultRevalidated)
+		return cached, true, nil
+	}
+	if err != nil {
+		b.cache.invalidate(key)
+		return nil, true, err
+	}
+	b.observeCache(cacheResultMiss)
+	b.cache.put(key, svc)
+	return svc, true, nil
+}
+
+func (b *ProdBackendController) observeCache(result string) {
+	if b.MetricCacheRequests != nil {
+		b.MetricCacheRequests.With(prometheus.Labels{"result": result}).Inc()
+	}
+}
+
+// refreshCache lists the backend services of the project and confirms or
+// drops the cached ones by comparing their fingerprints.
+func (b *ProdBackendController) refreshCache(ctx context.Context) error {
+	if err := b.wait(ctx, computeCallRead); err != nil {
+		return err
+	}
+	fingerprints := make(map[string]string)
+	now := time.Now()
+	err := compute.NewBackendServicesService(b.s).AggregatedList(b.project).
+		Fields("items/*/backendServices(name,fingerprint)", "nextPageToken").
+		Pages(ctx, func(page *compute.BackendServiceAggregatedList) error {
+			for scope, list := range page.Items {
+				region := strings.TrimPrefix(scope, "regions/")
+				if scope == "global" {
+					region = ""
+				}
+				for _, svc := range list.BackendServices {
+					fingerprints[backendServiceCacheKey(b.project, region, svc.Name)] = svc.Fingerprint
+				}
+			}
+			return nil
+		})
+	b.observeCall(err)
+	if err != nil {
+		return err
+	}
+	b.cache.confirm(fingerprints, now)
+	return nil
+}
+
+// Start periodically refreshes the backend service cache until the context
+// is done. It implements manager.Runnable.
+func (b *ProdBackendController) Start(ctx context.Context) error {
+	if b.cache == nil || b.cache.refreshInterval <= 0 {
+		return nil
+	}
+	logger := log.FromContext(ctx).WithName("backend-service-cache")
+	ticker := time.NewTicker(b.cache.refreshInterval)
+	defer ticker.Stop()
+	for {
+		if err := b.refreshCache(ctx); err != nil && ctx.Err() == nil {
+			logger.Error(err, "Failed to refresh backend service cache", "project", b.project)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection makes the cache refresh run on the leader only, as
+// the other replicas do not reconcile.
+func (b *ProdBackendController) NeedLeaderElection() bool {
+	return true
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// etagBackendServiceServer serves a single global backend service with an
+// ETag and counts the requests it receives
+type etagBackendServiceServer struct {
+	sync.Mutex
+	bs       compute.BackendService
+	etag     string
+	gets     int
+	notMods  int
+	lists    int
+	patches  int
+	failures []string
+}
+
+func (s *etagBackendServiceServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/aggregated/backendServices"):
+		s.lists++
+		json.NewEncoder(w).Encode(compute.BackendServiceAggregatedList{
+			Items: map[string]compute.BackendServicesScopedList{
+				"global": {BackendServices: []*compute.BackendService{{Name: s.bs.Name, Fingerprint: s.bs.Fingerprint}}},
+			},
+		})
+	case strings.HasSuffix(r.URL.Path, "/backendServices/"+s.bs.Name) && r.Method == http.MethodGet:
+		s.gets++
+		if r.Header.Get("If-None-Match") == s.etag {
+			s.notMods++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", s.etag)
+		json.NewEncoder(w).Encode(s.bs)
+	case strings.HasSuffix(r.URL.Path, "/backendServices/"+s.bs.Name) && r.Method == http.MethodPatch:
+		s.patches++
+		if r.Header.Get("If-Match") != s.etag {
+			s.failures = append(s.failures, "patch with outdated ETag "+r.Header.Get("If-Match"))
+		}
+		s.change("fp-patched", `"etag-patched"`)
+		json.NewEncoder(w).Encode(compute.Operation{Name: "op", Status: computeOperationStatusDone})
+	default:
+		s.failures = append(s.failures, "unexpected request "+r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (s *etagBackendServiceServer) change(fingerprint, etag string) {
+	s.bs.Fingerprint = fingerprint
+	s.etag = etag
+}
+
+func (s *etagBackendServiceServer) counts() (gets, notMods, lists int) {
+	s.Lock()
+	defer s.Unlock()
+	return s.gets, s.notMods, s.lists
+}
+
+func TestBackendServiceCache(t *testing.T) {
+	ctx := context.Background()
+	fs := &etagBackendServiceServer{
+		bs:   compute.BackendService{Name: "bs", Fingerprint: "fp-1", Backends: []*compute.Backend{{Group: "neg-1"}}},
+		etag: `"etag-1"`,
+	}
+	s := httptest.NewServer(fs)
+	defer s.Close()
+	cs, err := compute.NewService(ctx, option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("compute.NewService() got err: %v", err)
+	}
+	bc := NewBackendController("project", cs, BackendControllerOptions{
+		Cache: BackendServiceCacheOptions{Enabled: true, RefreshInterval: time.Hour},
+	})
+
+	check := func(step string, wantGets, wantNotMods, wantLists int) {
+		t.Helper()
+		gets, notMods, lists := fs.counts()
+		if gets != wantGets || notMods != wantNotMods || lists != wantLists {
+			t.Errorf("%s: got %d GETs (%d not modified) and %d lists, want %d (%d) and %d",
+				step, gets, notMods, lists, wantGets, wantNotMods, wantLists)
+		}
+	}
+	get := func(step string) *compute.BackendService {
+		t.Helper()
+		svc, err := bc.getBackendService(ctx, "bs", "")
+		if err != nil {
+			t.Fatalf("%s: getBackendService() got err: %v", step, err)
+		}
+		return svc
+	}
+
+	get("first read")
+	check("first read", 1, 0, 0)
+
+	// Callers modify the returned backend service, the cache is not affected.
+	svc := get("revalidated read")
+	check("revalidated read", 2, 1, 0)
+	svc.Backends = nil
+	if svc = get("read after modification"); len(svc.Backends) != 1 {
+		t.Errorf("cached backend service was modified by the caller: %+v", svc.Backends)
+	}
+	if svc.Header.Get("ETag") != `"etag-1"` {
+		t.Errorf("cached backend service has ETag %q, want %q", svc.Header.Get("ETag"), `"etag-1"`)
+	}
+	check("read after modification", 3, 2, 0)
+
+	// A listing with an unchanged fingerprint makes reads free.
+	if err := bc.refreshCache(ctx); err != nil {
+		t.Fatalf("refreshCache() got err: %v", err)
+	}
+	get("confirmed read")
+	check("confirmed read", 3, 2, 1)
+
+	// A changed fingerprint drops the entry.
+	fs.Lock()
+	fs.change("fp-2", `"etag-2"`)
+	fs.Unlock()
+	if err := bc.refreshCache(ctx); err != nil {
+		t.Fatalf("refreshCache() got err: %v", err)
+	}
+	get("read after drift")
+	check("read after drift", 4, 2, 2)
+
+	// Our own patches invalidate the entry.
+	if err := bc.refreshCache(ctx); err != nil {
+		t.Fatalf("refreshCache() got err: %v", err)
+	}
+	svc = get("read before patch")
+	check("read before patch", 4, 2, 3)
+	if _, err := bc.updateBackends(ctx, "bs", "", svc, nil, false); err != nil {
+		t.Fatalf("updateBackends() got err: %v", err)
+	}
+	if svc = get("read after patch"); svc.Header.Get("ETag") != `"etag-patched"` {
+		t.Errorf("read after patch got ETag %q, want %q", svc.Header.Get("ETag"), `"etag-patched"`)
+	}
+	check("read after patch", 5, 2, 3)
+
+	fs.Lock()
+	defer fs.Unlock()
+	for _, f := range fs.failures {
+		t.Error(f)
+	}
+}
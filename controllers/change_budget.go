@@ -0,0 +1,221 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// autonegChangePriorityAnnotation sets the priority of the changes of
+	// a service while the change budget is exhausted, higher first
+	autonegChangePriorityAnnotation = "controller.autoneg.dev/change-priority"
+
+	changeBudgetMutationWindow = time.Minute
+	changeBudgetServiceWindow  = 10 * time.Minute
+	// changeBudgetWaiterTimeout forgets services which stopped waiting for
+	// the budget, e.g. because they were deleted
+	changeBudgetWaiterTimeout = changeBudgetServiceWindow + time.Minute
+	changeBudgetMinRetry      = time.Second
+)
+
+// ChangeBudgetOptions configures the controller-wide budget of backend
+// service changes of ProdBackendController. Zero disables a limit.
+type ChangeBudgetOptions struct {
+	// MutationsPerMinute is the maximum number of backend service
+	// mutations of all services per minute.
+	MutationsPerMinute int
+	// BackendServicesPer10Minutes is the maximum number of distinct
+	// backend services changed per 10 minutes.
+	BackendServicesPer10Minutes int
+}
+
+// errChangeBudgetExhausted is returned by ReconcileBackends when a backend
+// service change exceeds the change budget. The change is queued and should
+// be retried after RetryAfter.
+type errChangeBudgetExhausted struct {
+	BackendService string
+	// Position is the number of queued changes before this one
+	Position   int
+	RetryAfter time.Duration
+}
+
+func (e *errChangeBudgetExhausted) Error() string {
+	return fmt.Sprintf("change budget exhausted: change of backend service %s queued behind %d changes, retrying in %s", e.BackendService, e.Position, e.RetryAfter)
+}
+
+// changeWaiter is a service waiting for the change budget
+type changeWaiter struct {
+	priority int
+	since    time.Time
+	seen     time.Time
+}
+
+// before returns true if the waiter gets the budget before the other one
+func (w changeWaiter) before(other changeWaiter) bool {
+	if w.priority != other.priority {
+		return w.priority > other.priority
+	}
+	return w.since.Before(other.since)
+}
+
+// changeBudget limits the backend service mutations per minute and the
+// distinct backend services changed per 10 minutes. Changes exceeding the
+// budget are queued by priority, then by the time they started waiting.
+type changeBudget struct {
+	sync.Mutex
+	opts ChangeBudgetOptions
+	// mutations are the times of the mutations within the last minute
+	mutations []time.Time
+	// changed are the times backend services were last changed within
+	// the last 10 minutes
+	changed map[string]time.Time
+	// waiting are the services waiting for the budget
+	waiting map[string]changeWaiter
+}
+
+func newChangeBudget(opts ChangeBudgetOptions) *changeBudget {
+	if opts.MutationsPerMinute <= 0 && opts.BackendServicesPer10Minutes <= 0 {
+		return nil
+	}
+	return &changeBudget{
+		opts:    opts,
+		changed: make(map[string]time.Time),
+		waiting: make(map[string]changeWaiter),
+	}
+}
+
+// parseChangePriority parses the priority of the changes of a service
+func parseChangePriority(value string) (int, error) {
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be an integer, but was %q", errConfigInvalid, autonegChangePriorityAnnotation, value)
+	}
+	return priority, nil
+}
+
+// expire forgets mutations, changed backend services and waiters which are
+// outside of their windows
+func (c *changeBudget) expire(now time.Time) {
+	i := 0
+	for i < len(c.mutations) && now.Sub(c.mutations[i]) >= changeBudgetMutationWindow {
+		i++
+	}
+	c.mutations = c.mutations[i:]
+	for key, changed := range c.changed {
+		if now.Sub(changed) >= changeBudgetServiceWindow {
+			delete(c.changed, key)
+		}
+	}
+	for service, w := range c.waiting {
+		if now.Sub(w.seen) >= changeBudgetWaiterTimeout {
+			delete(c.waiting, service)
+		}
+	}
+}
+
+// acquire spends a mutation of the backend service for the service, if
+// the budget left allows it after the changes queued before it. Otherwise
+// the service is queued and an *errChangeBudgetExhausted is returned.
+func (c *changeBudget) acquire(service string, priority int, backendService string, now time.Time) error {
+	c.Lock()
+	defer c.Unlock()
+	c.expire(now)
+
+	w, ok := c.waiting[service]
+	if !ok {
+		w = changeWaiter{since: now}
+	}
+	w.priority = priority
+	w.seen = now
+	// Every change queued before this one is assumed to need a mutation
+	// and a backend service of its own.
+	position := 0
+	for other, ow := range c.waiting {
+		if other != service && ow.before(w) {
+			position++
+		}
+	}
+
+	mutationsLeft, servicesLeft := math.MaxInt, math.MaxInt
+	if c.opts.MutationsPerMinute > 0 {
+		mutationsLeft = c.opts.MutationsPerMinute - len(c.mutations)
+	}
+	_, changedBefore := c.changed[backendService]
+	if c.opts.BackendServicesPer10Minutes > 0 && !changedBefore {
+		servicesLeft = c.opts.BackendServicesPer10Minutes - len(c.changed)
+	}
+	if position < mutationsLeft && position < servicesLeft {
+		c.mutations = append(c.mutations, now)
+		c.changed[backendService] = now
+		delete(c.waiting, service)
+		return nil
+	}
+
+	c.waiting[service] = w
+	return &errChangeBudgetExhausted{
+		BackendService: backendService,
+		Position:       position,
+		RetryAfter:     c.retryAfter(position, mutationsLeft, servicesLeft, now),
+	}
+}
+
+// retryAfter returns how long until the budget grows enough for the
+// changes queued before a change, and the change itself.
+func (c *changeBudget) retryAfter(position, mutationsLeft, servicesLeft int, now time.Time) time.Duration {
+	retry := changeBudgetMinRetry
+	if position >= mutationsLeft {
+		// The mutations expire in order.
+		if i := position - mutationsLeft; i < len(c.mutations) {
+			retry = max(retry, c.mutations[i].Add(changeBudgetMutationWindow).Sub(now))
+		}
+	}
+	if position >= servicesLeft {
+		oldest := now
+		for _, changed := range c.changed {
+			if changed.Before(oldest) {
+				oldest = changed
+			}
+		}
+		retry = max(retry, oldest.Add(changeBudgetServiceWindow).Sub(now))
+	}
+	return retry
+}
+
+// queued returns the number of services waiting for the budget
+func (c *changeBudget) queued() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.waiting)
+}
+
+// spendChangeBudget acquires the change budget for a mutation of a backend
+// service on behalf of the service of the intended status.
+func (b *ProdBackendController) spendChangeBudget(intended AutonegStatus, region string, name string) error {
+	if b.budget == nil {
+		return nil
+	}
+	err := b.budget.acquire(intended.serviceKey, intended.changePriority, backendServiceCacheKey(b.project, region, name), time.Now())
+	if b.MetricChangeBudgetQueued != nil {
+		b.MetricChangeBudgetQueued.Set(float64(b.budget.queued()))
+	}
+	return err
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestChangeBudgetMutations(t *testing.T) {
+	c := newChangeBudget(ChangeBudgetOptions{MutationsPerMinute: 1})
+	start := time.Now()
+
+	if err := c.acquire("ns/a", 0, "bs-a", start); err != nil {
+		t.Fatalf("acquire() got err: %v", err)
+	}
+	var budgetErr *errChangeBudgetExhausted
+	if err := c.acquire("ns/b", 0, "bs-b", start.Add(time.Second)); !errors.As(err, &budgetErr) {
+		t.Fatalf("acquire() got err %v, want budget exhausted", err)
+	}
+	if budgetErr.RetryAfter != 59*time.Second {
+		t.Errorf("acquire() got retry after %v, want 59s", budgetErr.RetryAfter)
+	}
+	// A change of higher priority is queued before the earlier one.
+	if err := c.acquire("ns/c", 1, "bs-c", start.Add(2*time.Second)); !errors.As(err, &budgetErr) || budgetErr.Position != 0 {
+		t.Fatalf("acquire() got err %v, want budget exhausted at position 0", err)
+	}
+	if got := c.queued(); got != 2 {
+		t.Errorf("queued() = %d, want 2", got)
+	}
+
+	later := start.Add(time.Minute + 5*time.Second)
+	if err := c.acquire("ns/b", 0, "bs-b", later); !errors.As(err, &budgetErr) || budgetErr.Position != 1 {
+		t.Fatalf("acquire() got err %v, want budget exhausted at position 1", err)
+	}
+	if err := c.acquire("ns/c", 1, "bs-c", later); err != nil {
+		t.Fatalf("acquire() got err: %v", err)
+	}
+	if got := c.queued(); got != 1 {
+		t.Errorf("queued() = %d, want 1", got)
+	}
+
+	// Services which stopped retrying are forgotten.
+	if err := c.acquire("ns/d", 0, "bs-d", later.Add(changeBudgetWaiterTimeout)); err != nil {
+		t.Fatalf("acquire() got err: %v", err)
+	}
+}
+
+func TestChangeBudgetBackendServices(t *testing.T) {
+	c := newChangeBudget(ChangeBudgetOptions{BackendServicesPer10Minutes: 1})
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if err := c.acquire("ns/a", 0, "bs-a", now); err != nil {
+			t.Fatalf("acquire() #%d of the same backend service got err: %v", i+1, err)
+		}
+	}
+	var budgetErr *errChangeBudgetExhausted
+	if err := c.acquire("ns/b", 0, "bs-b", now); !errors.As(err, &budgetErr) || budgetErr.RetryAfter != changeBudgetServiceWindow {
+		t.Fatalf("acquire() got err %v, want budget exhausted for %v", err, changeBudgetServiceWindow)
+	}
+	if err := c.acquire("ns/b", 0, "bs-b", now.Add(changeBudgetServiceWindow)); err != nil {
+		t.Fatalf("acquire() got err: %v", err)
+	}
+
+	if newChangeBudget(ChangeBudgetOptions{}) != nil {
+		t.Errorf("newChangeBudget() without limits is not nil")
+	}
+}
+
+func TestReconcileBackendsChangeBudget(t *testing.T) {
+	patches := 0
+	var backends []*compute.Backend
+	s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if req.Method == http.MethodPatch {
+			patches++
+			json.NewEncoder(res).Encode(compute.Operation{Name: "op", Status: computeOperationStatusDone})
+			return
+		}
+		json.NewEncoder(res).Encode(compute.BackendService{Name: "test", Backends: backends})
+	}))
+	defer s.Close()
+	cs, err := compute.NewService(context.Background(), option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to instantiate compute service: %v", err)
+	}
+	bc := ProdBackendController{project: fakeProject, s: cs, budget: newChangeBudget(ChangeBudgetOptions{MutationsPerMinute: 1})}
+
+	first := statusBasicWithNEGs
+	first.serviceKey = "ns/first"
+	if _, err := bc.ReconcileBackends(context.Background(), statusInitial, first, false); err != nil {
+		t.Fatalf("ReconcileBackends() got err: %v", err)
+	}
+	second := statusBasicWithNEGs
+	second.serviceKey = "ns/second"
+	var budgetErr *errChangeBudgetExhausted
+	if _, err := bc.ReconcileBackends(context.Background(), statusInitial, second, false); !errors.As(err, &budgetErr) {
+		t.Fatalf("ReconcileBackends() got err %v, want budget exhausted", err)
+	}
+	if patches != 1 {
+		t.Errorf("ReconcileBackends() patched %d times, want 1", patches)
+	}
+
+	// Removing backends spends the budget, deleting services does not.
+	backends = driftTestBackends(nil)
+	removal := second
+	removal.NEGStatus = NEGStatus{NEGs: negStatus.NEGs, Zones: []string{"zone1"}}
+	if _, err := bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, removal, false); !errors.As(err, &budgetErr) {
+		t.Fatalf("ReconcileBackends() removing a backend got err %v, want budget exhausted", err)
+	}
+	deletion := second
+	deletion.BackendServices = map[string]map[string]AutonegNEGConfig{}
+	if _, err := bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, deletion, true); err != nil {
+		t.Fatalf("ReconcileBackends() deleting got err: %v", err)
+	}
+	if patches != 2 {
+		t.Errorf("ReconcileBackends() patched %d times, want 2", patches)
+	}
+}
+
+func TestReconcileChangeBudgetExhausted(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	r := newTestReconciler(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: key.Namespace,
+			Name:      key.Name,
+			Annotations: map[string]string{
+				autonegAnnotation:               validConfig,
+				autonegChangePriorityAnnotation: "10",
+			},
+		},
+	})
+	bc := &recordingBackendController{err: &errChangeBudgetExhausted{BackendService: "http-be", RetryAfter: 30 * time.Second}}
+	r.BackendController = bc
+
+	res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	if err != nil || res.RequeueAfter != 30*time.Second {
+		t.Fatalf("Reconcile() got %+v, %v, want requeue after 30s", res, err)
+	}
+	if got := bc.intended[0]; got.serviceKey != "ns/svc" || got.changePriority != 10 {
+		t.Errorf("ReconcileBackends() got service %q with priority %d, want ns/svc with priority 10", got.serviceKey, got.changePriority)
+	}
+	svc := &corev1.Service{}
+	if err := r.Get(ctx, key, svc); err != nil {
+		t.Fatalf("Get() got err: %v", err)
+	}
+	if c := meta.FindStatusCondition(svc.Status.Conditions, conditionSynced); c == nil || c.Reason != "ChangeBudgetExhausted" {
+		t.Errorf("Synced condition = %+v, want reason ChangeBudgetExhausted", c)
+	}
+	if _, ok := svc.Annotations[autonegStatusAnnotation]; ok {
+		t.Errorf("Reconcile() stored the intended status of a queued change")
+	}
+}
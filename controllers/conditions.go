@@ -0,0 +1,67 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// conditionQuarantined is set on a Service once it exceeded the maximum
+	// number of consecutive reconciliation errors.
+	conditionQuarantined = "Quarantined"
+	// conditionSynced reports whether the backends were last reconciled
+	// successfully.
+	conditionSynced = "Synced"
+)
+
+// setCondition sets a condition on the Service status and persists it
+// if anything changed.
+func (r *ServiceReconciler) setCondition(ctx context.Context, svc *corev1.Service, conditionType string, status metav1.ConditionStatus, reason string, message string) error {
+	patch := client.MergeFrom(svc.DeepCopy())
+	changed := meta.SetStatusCondition(&svc.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: svc.Generation,
+	})
+	if !changed {
+		return nil
+	}
+	return r.Status().Patch(ctx, svc, patch)
+}
+
+// removeCondition removes a condition from the Service status and persists
+// it if it was present.
+func (r *ServiceReconciler) removeCondition(ctx context.Context, svc *corev1.Service, conditionType string) error {
+	patch := client.MergeFrom(svc.DeepCopy())
+	if !meta.RemoveStatusCondition(&svc.Status.Conditions, conditionType) {
+		return nil
+	}
+	return r.Status().Patch(ctx, svc, patch)
+}
+
+// isQuarantined returns true if the Service carries an active Quarantined condition.
+func isQuarantined(svc *corev1.Service) bool {
+	return meta.IsStatusConditionTrue(svc.Status.Conditions, conditionQuarantined)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Keys of the controller ConfigMap
+const (
+	// controllerConfigDrainedZones lists the zones whose backends are
+	// drained for all services, separated by commas or whitespace
+	controllerConfigDrainedZones = "drained-zones"
+	// controllerConfigDrainCluster drains the backends in all zones, for
+	// maintenance of the whole cluster
+	controllerConfigDrainCluster = "drain-cluster"
+	// controllerConfigDrainStep is the maximum change of the capacity
+	// scaler of a drained or restored backend per step, zero for no limit
+	controllerConfigDrainStep = "drain-step"
+	// controllerConfigDrainInterval is the time between drain steps
+	controllerConfigDrainInterval = "drain-interval"
+	// controllerConfigTrafficSplit holds the weights of the clusters sharing
+	// backend services, as JSON
+	controllerConfigTrafficSplit = "traffic-split"
+	// controllerConfigTrafficSplitInterval is the time between adjustments
+	// of the capacity scalers of split backend services
+	controllerConfigTrafficSplitInterval = "traffic-split-interval"
+	// controllerConfigFreeze stops all changes of backend services, while
+	// services are still read, reconciled and checked for drift
+	controllerConfigFreeze = "freeze"
+	// controllerConfigFreezeWindows holds the recurring windows during
+	// which changes other than removals of backends are deferred, as JSON
+	controllerConfigFreezeWindows = "freeze-windows"
+	// controllerConfigProtectedBackendServices lists the regular
+	// expressions of backend service names whose changes need approval,
+	// separated by whitespace
+	controllerConfigProtectedBackendServices = "protected-backend-services"
+)
+
+const (
+	// defaultDrainInterval is the time between drain steps if not configured
+	defaultDrainInterval = 30 * time.Second
+	// defaultTrafficSplitInterval is the time between adjustments of split
+	// backend services if not configured
+	defaultTrafficSplitInterval = time.Minute
+)
+
+// ControllerConfig holds the settings of the controller ConfigMap, which
+// apply to all services managed by the controller
+type ControllerConfig struct {
+	DrainedZones  []string
+	DrainCluster  bool
+	DrainStep     float64
+	DrainInterval time.Duration
+	TrafficSplit  TrafficSplit
+	// TrafficSplitInterval is the time between adjustments of split
+	// backend services
+	TrafficSplitInterval time.Duration
+	// Freeze refuses all changes of backend services
+	Freeze bool
+	// FreezeWindows defer changes other than removals of backends
+	FreezeWindows FreezeWindows
+	// ProtectedBackendServices match the names of backend services whose
+	// changes wait for approval
+	ProtectedBackendServices []*regexp.Regexp
+	// Version is the resource version of the ConfigMap, it changes with
+	// the settings
+	Version string
+}
+
+// parseControllerConfig parses the data of the controller ConfigMap
+func parseControllerConfig(data map[string]string) (ControllerConfig, error) {
+	cfg := ControllerConfig{DrainInterval: defaultDrainInterval, TrafficSplitInterval: defaultTrafficSplitInterval}
+	cfg.DrainedZones = splitList(data[controllerConfigDrainedZones])
+	var err error
+	if v, ok := data[controllerConfigDrainCluster]; ok {
+		if cfg.DrainCluster, err = strconv.ParseBool(strings.TrimSpace(v)); err != nil {
+			return cfg, fmt.Errorf("%w: %s %q is not a boolean", errConfigInvalid, controllerConfigDrainCluster, v)
+		}
+	}
+	if v, ok := data[controllerConfigDrainStep]; ok {
+		if cfg.DrainStep, err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil || cfg.DrainStep < 0 || cfg.DrainStep > 1 {
+			return cfg, fmt.Errorf("%w: %s %q must be between 0 and 1", errConfigInvalid, controllerConfigDrainStep, v)
+		}
+	}
+	if v, ok := data[controllerConfigDrainInterval]; ok {
+		if cfg.DrainInterval, err = time.ParseDuration(strings.TrimSpace(v)); err != nil || cfg.DrainInterval <= 0 {
+			return cfg, fmt.Errorf("%w: %s %q is not a positive duration", errConfigInvalid, controllerConfigDrainInterval, v)
+		}
+	}
+	if v, ok := data[controllerConfigTrafficSplit]; ok {
+		if cfg.TrafficSplit, err = parseTrafficSplit(v); err != nil {
+			return cfg, err
+		}
+	}
+	if v, ok := data[controllerConfigFreeze]; ok {
+		if cfg.Freeze, err = strconv.ParseBool(strings.TrimSpace(v)); err != nil {
+			return cfg, fmt.Errorf("%w: %s %q is not a boolean", errConfigInvalid, controllerConfigFreeze, v)
+		}
+	}
+	if v, ok := data[controllerConfigFreezeWindows]; ok {
+		if cfg.FreezeWindows, err = parseFreezeWindows(v); err != nil {
+			return cfg, err
+		}
+	}
+	if cfg.ProtectedBackendServices, err = parseProtectedBackendServices(data[controllerConfigProtectedBackendServices]); err != nil {
+		return cfg, err
+	}
+	if v, ok := data[controllerConfigTrafficSplitInterval]; ok {
+		if cfg.TrafficSplitInterval, err = time.ParseDuration(strings.TrimSpace(v)); err != nil || cfg.TrafficSplitInterval <= 0 {
+			return cfg, fmt.Errorf("%w: %s %q is not a positive duration", errConfigInvalid, controllerConfigTrafficSplitInterval, v)
+		}
+	}
+	return cfg, nil
+}
+
+// splitList splits a list separated by commas or whitespace into sorted
+// unique items
+func splitList(s string) []string {
+	items := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\t'
+	})
+	if len(items) == 0 {
+		return nil
+	}
+	slices.Sort(items)
+	return slices.Compact(items)
+}
+
+// controllerConfig reads the controller ConfigMap. A controller without a
+// ConfigMap, or a missing ConfigMap, has the default settings.
+func (r *ServiceReconciler) controllerConfig(ctx context.Context) (ControllerConfig, error) {
+	if r.ControllerConfigMap.Name == "" {
+		return parseControllerConfig(nil)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, r.ControllerConfigMap, cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return parseControllerConfig(nil)
+		}
+		return ControllerConfig{}, err
+	}
+	cfg, err := parseControllerConfig(cm.Data)
+	cfg.Version = cm.ResourceVersion
+	return cfg, err
+}
+
+// isControllerConfigMap returns true for the controller ConfigMap
+func (r *ServiceReconciler) isControllerConfigMap(obj client.Object) bool {
+	return obj.GetNamespace() == r.ControllerConfigMap.Namespace && obj.GetName() == r.ControllerConfigMap.Name
+}
+
+// managedServices enqueues all services managed by autoneg, on changes of
+// the controller ConfigMap.
+func (r *ServiceReconciler) managedServices(ctx context.Context, obj client.Object) []reconcile.Request {
+	services := &corev1.ServiceList{}
+	if err := r.List(ctx, services); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list services for controller config change")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, svc := range services.Items {
+		_, configured := svc.Annotations[autonegAnnotation]
+		_, reconciled := svc.Annotations[autonegStatusAnnotation]
+		if configured || reconciled {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: svc.Namespace, Name: svc.Name}})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronSchedule(t *testing.T) {
+	// 2026-03-02 is a Monday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		spec string
+		time time.Time
+		want bool
+	}{
+		{"30 9 * * 1-5", at(2, 9, 30), true},
+		{"30 9 * * 1-5", at(2, 9, 31), false},
+		{"30 9 * * 1-5", at(1, 9, 30), false},
+		{"*/15 * * * *", at(1, 3, 45), true},
+		{"*/15 * * * *", at(1, 3, 46), false},
+		{"0 0 * * 7", at(1, 0, 0), true},
+		{"0 8-18/2 * * *", at(3, 12, 0), true},
+		{"0 8-18/2 * * *", at(3, 13, 0), false},
+		{"0 0 1,15 * *", at(15, 0, 0), true},
+		// Restricted days of month and week match either.
+		{"0 0 1 * 1", at(2, 0, 0), true},
+		{"0 0 1 * 1", at(3, 0, 0), false},
+		{"0 0 * 4 *", at(2, 0, 0), false},
+		// Days starting with * restrict the other day field.
+		{"0 0 */2 * 1", at(2, 0, 0), false},
+		{"0 0 */2 * 1", at(9, 0, 0), true},
+		{"0 0 1 * */2", at(1, 0, 0), true},
+		{"0 0 1 * */2", at(3, 0, 0), false},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("parseCron(%q) got err: %v", tt.spec, err)
+		}
+		if got := s.matches(tt.time); got != tt.want {
+			t.Errorf("parseCron(%q).matches(%v) = %v, want %v", tt.spec, tt.time, got, tt.want)
+		}
+	}
+
+	prevTests := []struct {
+		spec  string
+		time  time.Time
+		after time.Time
+		want  time.Time
+	}{
+		{"30 9 * * 1-5", at(2, 12, 0), at(2, 0, 0), at(2, 9, 30)},
+		{"30 9 * * 1-5", at(2, 9, 29), at(1, 0, 0), time.Time{}},
+		{"0 0 1 * *", at(7, 23, 59), at(1, 0, 0), time.Time{}},
+		{"0 0 1 * *", at(7, 23, 59), at(0, 23, 59), at(1, 0, 0)},
+		{"*/15 * * * *", at(3, 4, 44), at(3, 0, 0), at(3, 4, 30)},
+	}
+	for _, tt := range prevTests {
+		s, err := parseCron(tt.spec)
+		if err != nil {
+			t.Fatalf("parseCron(%q) got err: %v", tt.spec, err)
+		}
+		got, ok := s.prev(tt.time, tt.after)
+		if !got.Equal(tt.want) || ok != !tt.want.IsZero() {
+			t.Errorf("parseCron(%q).prev(%v, %v) = %v, %v, want %v", tt.spec, tt.time, tt.after, got, ok, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("parseCron(%q) got no error", spec)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// conditionDrained reports whether the backends of drained zones or of a
+// drained cluster are at capacity zero
+const conditionDrained = "Drained"
+
+// computeHealthStateHealthy is the health state of healthy endpoints
+const computeHealthStateHealthy = "HEALTHY"
+
+// errDrainInProgress is returned by ReconcileBackends when backends are
+// drained or restored gradually and more steps are needed
+type errDrainInProgress struct {
+	Draining  int
+	Restoring int
+	// WaitingForHealth is set if the drain is held because no backend
+	// outside of the drain is healthy
+	WaitingForHealth bool
+}
+
+func (e *errDrainInProgress) Error() string {
+	if e.WaitingForHealth {
+		return fmt.Sprintf("drain held: waiting for healthy backends to shift traffic to (%d backends draining, %d restoring)", e.Draining, e.Restoring)
+	}
+	return fmt.Sprintf("drain in progress: %d backends draining, %d restoring", e.Draining, e.Restoring)
+}
+
+// newDrain returns the drain of the intended status for the controller
+// configuration, keeping the recorded capacities of backends which stay
+// drained.
+func newDrain(cfg ControllerConfig, actual *AutonegDrain) *AutonegDrain {
+	if len(cfg.DrainedZones) == 0 && !cfg.DrainCluster && actual == nil {
+		return nil
+	}
+	d := &AutonegDrain{Cluster: cfg.DrainCluster, Zones: slices.Clone(cfg.DrainedZones)}
+	if actual != nil {
+		record := func(group string, capacity float64) {
+			if d.drains(group) {
+				if d.Capacities == nil {
+					d.Capacities = make(map[string]float64)
+				}
+				d.Capacities[group] = capacity
+			}
+		}
+		// Backends drained again while being restored keep the capacity
+		// they are restored to.
+		for group, capacity := range actual.Restoring {
+			record(group, capacity)
+		}
+		for group, capacity := range actual.Capacities {
+			record(group, capacity)
+		}
+	}
+	return d
+}
+
+// drains returns true if the backend of the NEG is drained
+func (d *AutonegDrain) drains(group string) bool {
+	return d != nil && (d.Cluster || slices.Contains(d.Zones, groupZone(group)))
+}
+
+// capacity returns the recorded capacity scaler of the backend of the NEG
+func (d *AutonegDrain) capacity(group string) (float64, bool) {
+	if d == nil {
+		return 0, false
+	}
+	capacity, ok := d.Capacities[group]
+	return capacity, ok
+}
+
+// restoreTarget returns the capacity scaler a backend which is no longer
+// drained is restored to, if it was drained by the status.
+func (d *AutonegDrain) restoreTarget(group string) (target float64, recorded bool, ok bool) {
+	if d == nil {
+		return 0, false, false
+	}
+	if capacity, ok := d.Restoring[group]; ok {
+		return capacity, true, true
+	}
+	if capacity, ok := d.Capacities[group]; ok {
+		return capacity, true, true
+	}
+	return 0, false, d.drains(group)
+}
+
+// empty returns true if the drain neither drains nor restores backends
+func (d *AutonegDrain) empty() bool {
+	return d == nil || (!d.Cluster && len(d.Zones) == 0 && len(d.Capacities) == 0 && len(d.Restoring) == 0)
+}
+
+// isDrained returns true if the backend of the NEG is drained by the status
+func (s AutonegStatus) isDrained(group string) bool {
+	return s.Drain.drains(group)
+}
+
+// drainProgress counts the backends which did not reach their drained or
+// restored capacity scaler yet
+type drainProgress struct {
+	draining  int
+	restoring int
+	// reducing holds the current capacity scalers of the backends whose
+	// capacity scaler is reduced by this step
+	reducing map[string]float64
+}
+
+// applyDrain sets the intended capacity scaler of the backends in drained
+// zones to zero, and restores the recorded capacity scaler of backends whose
+// zone is no longer drained, by at most the drain step of the intended status
+// at a time.
+// The capacity scalers of backends being drained, and the ones backends are
+// restored to, are recorded in the drain of the intended status. It returns
+// the backends whose capacity scaler has to be synced regardless of the
+// field ownership.
+func applyDrain(actual, intended AutonegStatus, svc *compute.BackendService, backends []compute.Backend) (map[string]bool, drainProgress) {
+	synced := map[string]bool{}
+	progress := drainProgress{reducing: map[string]float64{}}
+	current := make(map[string]float64, len(svc.Backends))
+	for _, be := range svc.Backends {
+		current[be.Group] = be.CapacityScaler
+	}
+	for i := range backends {
+		u := &backends[i]
+		cur, exists := current[u.Group]
+		var target float64
+		draining := intended.isDrained(u.Group)
+		if draining {
+			if _, recorded := intended.Drain.capacity(u.Group); !recorded && !actual.isDrained(u.Group) && exists {
+				if intended.Drain.Capacities == nil {
+					intended.Drain.Capacities = make(map[string]float64)
+				}
+				intended.Drain.Capacities[u.Group] = cur
+			}
+		} else {
+			restored, recorded, ok := actual.Drain.restoreTarget(u.Group)
+			if !ok {
+				continue
+			}
+			target = u.CapacityScaler
+			if recorded {
+				target = restored
+			}
+		}
+
+		desired := target
+		if intended.drainStep > 0 && exists {
+			if cur > target {
+				desired = math.Max(target, cur-intended.drainStep)
+			} else {
+				desired = math.Min(target, cur+intended.drainStep)
+			}
+		}
+		if exists && desired < cur {
+			progress.reducing[u.Group] = cur
+		}
+		if desired != target {
+			if draining {
+				progress.draining++
+			} else {
+				progress.restoring++
+				if intended.Drain.Restoring == nil {
+					intended.Drain.Restoring = make(map[string]float64)
+				}
+				intended.Drain.Restoring[u.Group] = target
+			}
+		}
+		u.CapacityScaler = desired
+		synced[u.Group] = true
+	}
+	return synced, progress
+}
+
+// holdDrain keeps the current capacity scalers of backends which would be
+// reduced, while no backend outside of the drain is healthy.
+func holdDrain(backends []compute.Backend, progress drainProgress) {
+	for i := range backends {
+		if cur, ok := progress.reducing[backends[i].Group]; ok {
+			backends[i].CapacityScaler = cur
+		}
+	}
+}
+
+// syncCapacity returns the sync configuration with the capacity scaler owned
+func syncCapacity(sync *AutonegSyncConfig) *AutonegSyncConfig {
+	merged := AutonegSyncConfig{}
+	if sync != nil {
+		merged = *sync
+	}
+	owned := true
+	merged.CapacityScaler = &owned
+	return &merged
+}
+
+// pendingDrain returns the drain to store with the status while compute
+// operations are pending, so recorded capacities are not lost. It is marked
+// in progress, so the drain is applied again once the operations finished.
+func pendingDrain(actual, intended *AutonegDrain) *AutonegDrain {
+	if intended == nil {
+		return actual
+	}
+	d := &AutonegDrain{InProgress: true}
+	if actual != nil {
+		d.Cluster = actual.Cluster
+		d.Zones = actual.Zones
+		d.Capacities = maps.Clone(actual.Capacities)
+	}
+	for group, capacity := range intended.Capacities {
+		if d.Capacities == nil {
+			d.Capacities = make(map[string]float64)
+		}
+		d.Capacities[group] = capacity
+	}
+	d.Restoring = maps.Clone(intended.Restoring)
+	return d
+}
+
+// reportDrain records the progress of a drain as events and the Drained
+// condition of the service.
+func (r *ServiceReconciler) reportDrain(ctx context.Context, logger logr.Logger, svc *corev1.Service, drain *AutonegDrain, progress *errDrainInProgress) {
+	var err error
+	switch {
+	case progress != nil:
+		reason := "DrainInProgress"
+		if progress.WaitingForHealth {
+			reason = "WaitingForHealthyBackends"
+		} else if progress.Draining == 0 {
+			reason = "RestoreInProgress"
+		}
+		r.Recorder.Event(svc, "Normal", reason, progress.Error())
+		err = r.setCondition(ctx, svc, conditionDrained, metav1.ConditionFalse, reason, progress.Error())
+	case drain != nil && drain.Cluster:
+		err = r.setCondition(ctx, svc, conditionDrained, metav1.ConditionTrue, "Drained", "Backends in all zones are drained")
+	case drain != nil && len(drain.Zones) > 0:
+		err = r.setCondition(ctx, svc, conditionDrained, metav1.ConditionTrue, "Drained", fmt.Sprintf("Backends in zones %s are drained", strings.Join(drain.Zones, ", ")))
+	default:
+		err = r.removeCondition(ctx, svc, conditionDrained)
+	}
+	if err != nil {
+		logger.Error(err, "Failed to update service status")
+	}
+}
+
+// hasHealthyBackends returns true if any of the given backends of the
+// backend service has a healthy endpoint.
+func (b *ProdBackendController) hasHealthyBackends(ctx context.Context, name string, region string, groups []string) (bool, error) {
+	logger := log.FromContext(ctx)
+	for _, group := range groups {
+		if err := b.wait(ctx, computeCallRead); err != nil {
+			return false, err
+		}
+		ref := &compute.ResourceGroupReference{Group: group}
+		var health *compute.BackendServiceGroupHealth
+		var err error
+		if region == "" {
+			health, err = compute.NewBackendServicesService(b.s).GetHealth(b.project, name, ref).Do()
+		} else {
+			health, err = compute.NewRegionBackendServicesService(b.s).GetHealth(b.project, region, name, ref).Do()
+		}
+		b.observeCall(err)
+		if e, ok := err.(*googleapi.Error); ok && e.Code == 404 {
+			continue
+		}
+		if err != nil {
+			logger.Error(err, "Failed to get health of gcp backend", "project", b.project, "region", region, "name", name, "group", group)
+			return false, err
+		}
+		for _, hs := range health.HealthStatus {
+			if hs.HealthState == computeHealthStateHealthy {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,410 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestParseControllerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    ControllerConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: ControllerConfig{DrainInterval: defaultDrainInterval, TrafficSplitInterval: defaultTrafficSplitInterval},
+		},
+		{
+			name: "cluster drain",
+			data: map[string]string{
+				controllerConfigDrainCluster:  "true",
+				controllerConfigDrainStep:     "0.25",
+				controllerConfigDrainInterval: "1m",
+			},
+			want: ControllerConfig{DrainCluster: true, DrainStep: 0.25, DrainInterval: time.Minute, TrafficSplitInterval: defaultTrafficSplitInterval},
+		},
+		{
+			name:    "invalid cluster drain",
+			data:    map[string]string{controllerConfigDrainCluster: "yes please"},
+			wantErr: true,
+		},
+		{
+			name:    "step out of range",
+			data:    map[string]string{controllerConfigDrainStep: "2"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid interval",
+			data:    map[string]string{controllerConfigDrainInterval: "0s"},
+			wantErr: true,
+		},
+		{
+			name: "freeze",
+			data: map[string]string{controllerConfigFreeze: "true"},
+			want: ControllerConfig{Freeze: true, DrainInterval: defaultDrainInterval, TrafficSplitInterval: defaultTrafficSplitInterval},
+		},
+		{
+			name:    "invalid freeze",
+			data:    map[string]string{controllerConfigFreeze: "now"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid protected backend services",
+			data:    map[string]string{controllerConfigProtectedBackendServices: "prod-[edge"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseControllerConfig(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseControllerConfig() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseControllerConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileBackendsZoneDrain(t *testing.T) {
+	zone1 := getGroup(fakeProject, "zone1", fakeNeg)
+	zone2 := getGroup(fakeProject, "zone2", fakeNeg)
+	// The capacity scalers were set out-of-band.
+	capacities := map[string]float64{zone1: 0.7, zone2: 0.7}
+	var patched map[string]float64
+	s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if req.Method == http.MethodPatch {
+			var body compute.BackendService
+			json.NewDecoder(req.Body).Decode(&body)
+			patched = map[string]float64{}
+			for _, be := range body.Backends {
+				patched[be.Group] = be.CapacityScaler
+				capacities[be.Group] = be.CapacityScaler
+			}
+			json.NewEncoder(res).Encode(compute.Operation{Name: "op", Status: computeOperationStatusDone})
+			return
+		}
+		backends := driftTestBackends(func(zone string, be *compute.Backend) {
+			be.CapacityScaler = capacities[be.Group]
+		})
+		json.NewEncoder(res).Encode(compute.BackendService{Name: "test", Backends: backends})
+	}))
+	defer s.Close()
+	cs, err := compute.NewService(context.Background(), option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to instantiate compute service: %v", err)
+	}
+	bc := ProdBackendController{project: fakeProject, s: cs}
+
+	// Draining zone1 records its capacity scaler and sets it to zero.
+	drained := statusBasicWithNEGs
+	drained.Drain = newDrain(ControllerConfig{DrainedZones: []string{"zone1"}}, nil)
+	if _, err := bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, drained, false); err != nil {
+		t.Fatalf("ReconcileBackends() got err: %v", err)
+	}
+	if want := map[string]float64{zone1: 0, zone2: 0.7}; !reflect.DeepEqual(patched, want) {
+		t.Errorf("ReconcileBackends() draining zone1 patched capacities %v, want %v", patched, want)
+	}
+	if want := map[string]float64{zone1: 0.7}; !reflect.DeepEqual(drained.Drain.Capacities, want) {
+		t.Errorf("ReconcileBackends() recorded capacities %v, want %v", drained.Drain.Capacities, want)
+	}
+
+	// Reconciling the drained zone again keeps the recorded capacity.
+	patched = nil
+	again := statusBasicWithNEGs
+	again.Drain = newDrain(ControllerConfig{DrainedZones: []string{"zone1"}}, drained.Drain)
+	if _, err := bc.ReconcileBackends(context.Background(), drained, again, false); err != nil {
+		t.Fatalf("ReconcileBackends() got err: %v", err)
+	}
+	if patched != nil {
+		t.Errorf("ReconcileBackends() of a drained zone patched capacities %v, want no patch", patched)
+	}
+	if !reflect.DeepEqual(again.Drain, drained.Drain) {
+		t.Errorf("ReconcileBackends() changed the drain to %+v, want %+v", again.Drain, drained.Drain)
+	}
+
+	// Removing the drain restores the recorded capacity scaler.
+	restored := statusBasicWithNEGs
+	restored.Drain = newDrain(ControllerConfig{}, again.Drain)
+	if _, err := bc.ReconcileBackends(context.Background(), again, restored, false); err != nil {
+		t.Fatalf("ReconcileBackends() got err: %v", err)
+	}
+	if want := map[string]float64{zone1: 0.7, zone2: 0.7}; !reflect.DeepEqual(patched, want) {
+		t.Errorf("ReconcileBackends() undraining zone1 patched capacities %v, want %v", patched, want)
+	}
+}
+
+func TestReconcileBackendsSteppedClusterDrain(t *testing.T) {
+	zone1 := getGroup(fakeProject, "zone1", fakeNeg)
+	zone2 := getGroup(fakeProject, "zone2", fakeNeg)
+	// A backend of another cluster takes the traffic of the drained ones.
+	other := getGroup(fakeProject, "zone1", "other-neg")
+	capacities := map[string]float64{zone1: 1, zone2: 1}
+	healthy := false
+	var patched map[string]float64
+	s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/getHealth"):
+			state := "UNHEALTHY"
+			if healthy {
+				state = computeHealthStateHealthy
+			}
+			json.NewEncoder(res).Encode(compute.BackendServiceGroupHealth{HealthStatus: []*compute.HealthStatus{{HealthState: state}}})
+		case req.Method == http.MethodPatch:
+			var body compute.BackendService
+			json.NewDecoder(req.Body).Decode(&body)
+			patched = map[string]float64{}
+			for _, be := range body.Backends {
+				patched[be.Group] = be.CapacityScaler
+				if be.Group != other {
+					capacities[be.Group] = be.CapacityScaler
+				}
+			}
+			json.NewEncoder(res).Encode(compute.Operation{Name: "op", Status: computeOperationStatusDone})
+		default:
+			backends := driftTestBackends(func(zone string, be *compute.Backend) {
+				be.CapacityScaler = capacities[be.Group]
+			})
+			backends = append(backends, &compute.Backend{Group: other, BalancingMode: "RATE", MaxRatePerEndpoint: 100, CapacityScaler: 1})
+			json.NewEncoder(res).Encode(compute.BackendService{Name: "test", Backends: backends})
+		}
+	}))
+	defer s.Close()
+	cs, err := compute.NewService(context.Background(), option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to instantiate compute service: %v", err)
+	}
+	bc := ProdBackendController{project: fakeProject, s: cs}
+	cfg := ControllerConfig{DrainCluster: true, DrainStep: 0.5}
+	step := func(actual AutonegStatus, cfg ControllerConfig) (AutonegStatus, *errDrainInProgress) {
+		t.Helper()
+		intended := statusBasicWithNEGs
+		intended.Drain = newDrain(cfg, actual.Drain)
+		intended.drainStep = cfg.DrainStep
+		patched = nil
+		_, err := bc.ReconcileBackends(context.Background(), actual, intended, false)
+		var progress *errDrainInProgress
+		if err != nil && !errors.As(err, &progress) {
+			t.Fatalf("ReconcileBackends() got err: %v", err)
+		}
+		if progress != nil {
+			intended.Drain.InProgress = true
+		}
+		return intended, progress
+	}
+
+	// The drain is held while no other backend is healthy.
+	drained, progress := step(statusBasicWithNEGs, cfg)
+	if progress == nil || !progress.WaitingForHealth {
+		t.Fatalf("ReconcileBackends() got progress %+v, want waiting for health", progress)
+	}
+	if patched != nil {
+		t.Errorf("ReconcileBackends() waiting for health patched capacities %v, want no patch", patched)
+	}
+
+	// Each step lowers the capacity scalers by at most the drain step.
+	healthy = true
+	drained, progress = step(statusBasicWithNEGs, cfg)
+	if progress == nil || progress.Draining != 2 {
+		t.Fatalf("ReconcileBackends() got progress %+v, want 2 backends draining", progress)
+	}
+	if want := map[string]float64{zone1: 0.5, zone2: 0.5, other: 1}; !reflect.DeepEqual(patched, want) {
+		t.Errorf("ReconcileBackends() first step patched capacities %v, want %v", patched, want)
+	}
+	drained, progress = step(drained, cfg)
+	if progress != nil {
+		t.Fatalf("ReconcileBackends() got progress %+v, want drain completed", progress)
+	}
+	if want := map[string]float64{zone1: 0, zone2: 0, other: 1}; !reflect.DeepEqual(patched, want) {
+		t.Errorf("ReconcileBackends() second step patched capacities %v, want %v", patched, want)
+	}
+	if want := map[string]float64{zone1: 1, zone2: 1}; !reflect.DeepEqual(drained.Drain.Capacities, want) {
+		t.Errorf("ReconcileBackends() recorded capacities %v, want %v", drained.Drain.Capacities, want)
+	}
+
+	// Undraining restores the recorded capacity scalers step by step.
+	restored, progress := step(drained, ControllerConfig{DrainStep: 0.5})
+	if progress == nil || progress.Restoring != 2 {
+		t.Fatalf("ReconcileBackends() got progress %+v, want 2 backends restoring", progress)
+	}
+	if want := map[string]float64{zone1: 1, zone2: 1}; !reflect.DeepEqual(restored.Drain.Restoring, want) {
+		t.Errorf("ReconcileBackends() restoring capacities %v, want %v", restored.Drain.Restoring, want)
+	}
+	restored, progress = step(restored, ControllerConfig{DrainStep: 0.5})
+	if progress != nil {
+		t.Fatalf("ReconcileBackends() got progress %+v, want restore completed", progress)
+	}
+	if want := map[string]float64{zone1: 1, zone2: 1, other: 1}; !reflect.DeepEqual(patched, want) {
+		t.Errorf("ReconcileBackends() last restore step patched capacities %v, want %v", patched, want)
+	}
+	if !restored.Drain.empty() {
+		t.Errorf("ReconcileBackends() left drain %+v, want empty", restored.Drain)
+	}
+}
+
+type recordingBackendController struct {
+	intended []AutonegStatus
+	err      error
+}
+
+func (f *recordingBackendController) ReconcileBackends(ctx context.Context, actual, intended AutonegStatus, deleting bool) ([]BackendDrift, error) {
+	f.intended = append(f.intended, intended)
+	return nil, f.err
+}
+
+func (f *recordingBackendController) CheckOperations(ctx context.Context, ops []AutonegOperation) ([]AutonegOperation, error) {
+	return nil, nil
+}
+
+func TestReconcileControllerConfigDrainedZones(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	r := newTestReconciler(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   key.Namespace,
+			Name:        key.Name,
+			Annotations: map[string]string{autonegAnnotation: validConfig},
+		},
+	})
+	bc := &recordingBackendController{}
+	r.BackendController = bc
+	r.ControllerConfigMap = types.NamespacedName{Namespace: "autoneg-system", Name: "autoneg-controller-config"}
+	req := ctrl.Request{NamespacedName: key}
+
+	// A missing ConfigMap drains nothing.
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+	if bc.intended[0].Drain != nil {
+		t.Errorf("Reconcile() without ConfigMap got drain %+v, want none", bc.intended[0].Drain)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: r.ControllerConfigMap.Namespace, Name: r.ControllerConfigMap.Name},
+		Data:       map[string]string{controllerConfigDrainedZones: "us-central1-b, us-central1-a\nus-central1-b"},
+	}
+	if err := r.Create(ctx, cm); err != nil {
+		t.Fatalf("Create() got err: %v", err)
+	}
+	if got := r.managedServices(ctx, cm); len(got) != 1 || got[0].NamespacedName != key {
+		t.Errorf("managedServices() = %v, want %v", got, key)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+	if got, want := bc.intended[1].Drain, (&AutonegDrain{Zones: []string{"us-central1-a", "us-central1-b"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Reconcile() got drain %+v, want %+v", got, want)
+	}
+
+	// The drain is stored with the status.
+	svc := &corev1.Service{}
+	if err := r.Get(ctx, key, svc); err != nil {
+		t.Fatalf("Get() got err: %v", err)
+	}
+	var status AutonegStatus
+	if err := json.Unmarshal([]byte(svc.Annotations[autonegStatusAnnotation]), &status); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if !reflect.DeepEqual(status.Drain, bc.intended[1].Drain) {
+		t.Errorf("stored drain %+v, want %+v", status.Drain, bc.intended[1].Drain)
+	}
+}
+
+func TestReconcileDrainInProgress(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	r := newTestReconciler(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   key.Namespace,
+			Name:        key.Name,
+			Annotations: map[string]string{autonegAnnotation: validConfig},
+		},
+	})
+	bc := &recordingBackendController{err: &errDrainInProgress{Draining: 2}}
+	r.BackendController = bc
+	r.ControllerConfigMap = types.NamespacedName{Namespace: "autoneg-system", Name: "autoneg-controller-config"}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: r.ControllerConfigMap.Namespace, Name: r.ControllerConfigMap.Name},
+		Data: map[string]string{
+			controllerConfigDrainCluster:  "true",
+			controllerConfigDrainStep:     "0.1",
+			controllerConfigDrainInterval: "10s",
+		},
+	}
+	if err := r.Create(ctx, cm); err != nil {
+		t.Fatalf("Create() got err: %v", err)
+	}
+	req := ctrl.Request{NamespacedName: key}
+
+	res, err := r.Reconcile(ctx, req)
+	if err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+	if res.RequeueAfter != 10*time.Second {
+		t.Errorf("Reconcile() requeued after %v, want the drain interval", res.RequeueAfter)
+	}
+	if got := bc.intended[0].drainStep; got != 0.1 {
+		t.Errorf("Reconcile() got drain step %v, want 0.1", got)
+	}
+	svc := &corev1.Service{}
+	if err := r.Get(ctx, key, svc); err != nil {
+		t.Fatalf("Get() got err: %v", err)
+	}
+	cond := meta.FindStatusCondition(svc.Status.Conditions, conditionDrained)
+	if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "DrainInProgress" {
+		t.Errorf("Reconcile() got Drained condition %+v, want drain in progress", cond)
+	}
+
+	// The stored status is not up to date until the drain completed, so the
+	// next step is applied.
+	bc.err = nil
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+	if len(bc.intended) != 2 {
+		t.Fatalf("Reconcile() did not apply the next drain step")
+	}
+	if err := r.Get(ctx, key, svc); err != nil {
+		t.Fatalf("Get() got err: %v", err)
+	}
+	cond = meta.FindStatusCondition(svc.Status.Conditions, conditionDrained)
+	if cond == nil || cond.Status != metav1.ConditionTrue {
+		t.Errorf("Reconcile() got Drained condition %+v, want drained", cond)
+	}
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Drift policies, set with the controller.autoneg.dev/drift-policy annotation
+const (
+	// driftPolicyEnforce reports drift and corrects it, the default
+	driftPolicyEnforce = "enforce"
+	// driftPolicyReportOnly reports drift and leaves drifted backends alone
+	driftPolicyReportOnly = "report-only"
+	// driftPolicyIgnore neither reports nor corrects drift
+	driftPolicyIgnore = "ignore"
+)
+
+// Kinds of drift between the backends autoneg applied and the backend service
+const (
+	driftBackendRemoved  = "BackendRemoved"
+	driftBackendModified = "BackendModified"
+	driftUnknownBackend  = "UnknownBackend"
+)
+
+// conditionDrifted reports whether the backends were changed out-of-band
+const conditionDrifted = "Drifted"
+
+// BackendDrift describes a backend which was changed outside of autoneg
+// since it was last reconciled
+type BackendDrift struct {
+	BackendService string
+	Region         string
+	Group          string
+	Kind           string
+	Fields         []string
+}
+
+func (d BackendDrift) String() string {
+	s := fmt.Sprintf("%s %s in backend service %q", d.Kind, d.Group, d.BackendService)
+	if d.Region != "" {
+		s = fmt.Sprintf("%s (region %s)", s, d.Region)
+	}
+	if len(d.Fields) > 0 {
+		s = fmt.Sprintf("%s: %s", s, strings.Join(d.Fields, ", "))
+	}
+	return s
+}
+
+func validateDriftPolicy(policy string) error {
+	switch policy {
+	case driftPolicyEnforce, driftPolicyReportOnly, driftPolicyIgnore:
+		return nil
+	}
+	return fmt.Errorf("%w: drift policy %q must be one of %s, %s or %s", errConfigInvalid, policy, driftPolicyEnforce, driftPolicyReportOnly, driftPolicyIgnore)
+}
+
+// statusGroups returns the backend groups of a port of the status
+func statusGroups(project string, s AutonegStatus, port string) map[string]struct{} {
+	groups := make(map[string]struct{})
+	neg, ok := s.NEGs[port]
+	if !ok {
+		return groups
+	}
+	for _, zone := range s.Zones {
+		groups[getGroup(project, zone, neg)] = struct{}{}
+	}
+	return groups
+}
+
+// detectDrift compares the backends of the backend service with the ones
+// autoneg applied for the given port and backend service configuration.
+// Only backends which autoneg would not change anyway are considered, so a
+// backend service or configuration which changed since the last
+// reconciliation is never reported as drifted.
+func detectDrift(project string, actual, intended AutonegStatus, port string, name string, svc *compute.BackendService) []BackendDrift {
+	actualCfg, ok := actual.BackendServices[port][name]
+	if !ok {
+		return nil
+	}
+	intendedCfg, ok := intended.BackendServices[port][name]
+	if !ok || actualCfg.Name != intendedCfg.Name || actualCfg.Region != intendedCfg.Region {
+		return nil
+	}
+	sync := intended.SyncConfig(port, name)
+	if !reflect.DeepEqual(actualCfg, intendedCfg) || !reflect.DeepEqual(actual.SyncConfig(port, name), sync) {
+		return nil
+	}
+
+	actualGroups := statusGroups(project, actual, port)
+	intendedGroups := statusGroups(project, intended, port)
+	current := make(map[string]*compute.Backend, len(svc.Backends))
+	for _, be := range svc.Backends {
+		current[be.Group] = be
+	}
+
+	var drift []BackendDrift
+	newDrift := func(group, kind string, fields []string) BackendDrift {
+		return BackendDrift{BackendService: intendedCfg.Name, Region: intendedCfg.Region, Group: group, Kind: kind, Fields: fields}
+	}
+	for group := range intendedGroups {
+		if _, ok := actualGroups[group]; !ok || !actual.attached(name, port, group) || !intended.attached(name, port, group) {
+			continue
+		}
+		be, ok := current[group]
+		if !ok {
+			drift = append(drift, newDrift(group, driftBackendRemoved, nil))
+			continue
+		}
+		want := intended.Backend(name, port, group)
+		sync := intended.BackendSyncConfig(port, name, group)
+		var fields []string
+		if sync.Owns(syncFieldBalancingMode) && be.BalancingMode != want.BalancingMode {
+			fields = append(fields, "balancingMode")
+		}
+		if sync.Owns(syncFieldRates) && be.MaxRatePerEndpoint != want.MaxRatePerEndpoint {
+			fields = append(fields, "maxRatePerEndpoint")
+		}
+		if sync.Owns(syncFieldRates) && be.MaxConnectionsPerEndpoint != want.MaxConnectionsPerEndpoint {
+			fields = append(fields, "maxConnectionsPerEndpoint")
+		}
+		if sync.Owns(syncFieldRates) && be.MaxRate != want.MaxRate {
+			fields = append(fields, "maxRate")
+		}
+		if sync.Owns(syncFieldRates) && be.MaxConnections != want.MaxConnections {
+			fields = append(fields, "maxConnections")
+		}
+		if sync.Owns(syncFieldCustomMetrics) && !equalCustomMetrics(be.CustomMetrics, want.CustomMetrics) {
+			fields = append(fields, "customMetrics")
+		}
+		_, _, restoring := actual.Drain.restoreTarget(group)
+		drained := intended.isDrained(group) || restoring
+		_, split := intended.trafficSplit[trafficSplitKey(intendedCfg.Region, intendedCfg.Name)]
+		if sync.Owns(syncFieldCapacityScaler) && !drained && !split && be.CapacityScaler != want.CapacityScaler {
+			fields = append(fields, "capacityScaler")
+		}
+		if sync.Owns(syncFieldFailover) && be.Failover != want.Failover {
+			fields = append(fields, "failover")
+		}
+		if sync.Owns(syncFieldDescription) && be.Description != want.Description {
+			fields = append(fields, "description")
+		}
+		if len(fields) > 0 {
+			drift = append(drift, newDrift(group, driftBackendModified, fields))
+		}
+	}
+
+	// Backends of NEGs of this service which autoneg did not add, e.g. in
+	// a zone the NEG controller no longer reports.
+	if neg, ok := intended.NEGs[port]; ok && neg != "" {
+		for _, be := range svc.Backends {
+			if !strings.HasSuffix(be.Group, "/networkEndpointGroups/"+neg) {
+				continue
+			}
+			_, inActual := actualGroups[be.Group]
+			_, inIntended := intendedGroups[be.Group]
+			if !inActual && !inIntended {
+				drift = append(drift, newDrift(be.Group, driftUnknownBackend, nil))
+			}
+		}
+	}
+	return drift
+}
+
+func equalCustomMetrics(left, right []*compute.BackendCustomMetric) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for i := range left {
+		if left[i].Name != right[i].Name || left[i].DryRun != right[i].DryRun || left[i].MaxUtilization != right[i].MaxUtilization {
+			return false
+		}
+	}
+	return true
+}
+
+// reportDrift records the detected drift as events, metrics and the Drifted
+// condition of the service, according to the drift policy.
+func (r *ServiceReconciler) reportDrift(ctx context.Context, logger logr.Logger, svc *corev1.Service, policy string, drift []BackendDrift) {
+	if policy == driftPolicyIgnore {
+		if err := r.removeCondition(ctx, svc, conditionDrifted); err != nil {
+			logger.Error(err, "Failed to update service status")
+		}
+		return
+	}
+	if len(drift) == 0 {
+		if err := r.setCondition(ctx, svc, conditionDrifted, metav1.ConditionFalse, "NoDrift", "Backends match the applied configuration"); err != nil {
+			logger.Error(err, "Failed to update service status")
+		}
+		return
+	}
+
+	reason, action := "DriftCorrected", "corrected"
+	if policy == driftPolicyReportOnly {
+		reason, action = "DriftReported", "not corrected"
+	}
+	reported := reportedDrift(meta.FindStatusCondition(svc.Status.Conditions, conditionDrifted), reason)
+	messages := make([]string, 0, len(drift))
+	for _, d := range drift {
+		messages = append(messages, d.String())
+		if reported[d.String()] {
+			// Drift left alone is reported again on every
+			// reconciliation, it is only counted once.
+			continue
+		}
+		logger.Info("Backend drift detected", "backendService", d.BackendService, "region", d.Region, "group", d.Group, "kind", d.Kind, "fields", d.Fields, "policy", policy)
+		r.Recorder.Eventf(svc, "Warning", "DriftDetected", "%s, %s by policy %s", d, action, policy)
+		if r.MetricDriftDetected != nil {
+			r.MetricDriftDetected.With(prometheus.Labels{"namespace": svc.Namespace, "service": svc.Name, "kind": d.Kind}).Inc()
+		}
+	}
+	message := fmt.Sprintf("Backends changed out-of-band (%s): %s", action, strings.Join(messages, "; "))
+	if err := r.setCondition(ctx, svc, conditionDrifted, metav1.ConditionTrue, reason, message); err != nil {
+		logger.Error(err, "Failed to update service status")
+	}
+}
+
+// reportedDrift returns the drift listed by the Drifted condition, if it
+// is true with the given reason
+func reportedDrift(cond *metav1.Condition, reason string) map[string]bool {
+	reported := map[string]bool{}
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != reason {
+		return reported
+	}
+	if _, list, ok := strings.Cut(cond.Message, "): "); ok {
+		for _, d := range strings.Split(list, "; ") {
+			reported[d] = true
+		}
+	}
+	return reported
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func driftTestBackends(modify func(zone string, be *compute.Backend)) []*compute.Backend {
+	var backends []*compute.Backend
+	for _, zone := range negStatus.Zones {
+		be := statusBasicWithNEGs.Backend("test", "80", getGroup(fakeProject, zone, fakeNeg))
+		if modify != nil {
+			modify(zone, &be)
+		}
+		backends = append(backends, &be)
+	}
+	return backends
+}
+
+func TestDetectDrift(t *testing.T) {
+	zone1 := getGroup(fakeProject, "zone1", fakeNeg)
+	unknown := getGroup(fakeProject, "zone9", fakeNeg)
+	tests := []struct {
+		name     string
+		actual   AutonegStatus
+		intended AutonegStatus
+		backends []*compute.Backend
+		want     []BackendDrift
+	}{
+		{
+			name:     "no drift",
+			actual:   statusBasicWithNEGs,
+			intended: statusBasicWithNEGs,
+			backends: driftTestBackends(nil),
+		},
+		{
+			name:     "removed backend",
+			actual:   statusBasicWithNEGs,
+			intended: statusBasicWithNEGs,
+			backends: driftTestBackends(nil)[1:],
+			want:     []BackendDrift{{BackendService: "test", Group: zone1, Kind: driftBackendRemoved}},
+		},
+		{
+			name:     "modified backend",
+			actual:   statusBasicWithNEGs,
+			intended: statusBasicWithNEGs,
+			backends: driftTestBackends(func(zone string, be *compute.Backend) {
+				if zone == "zone1" {
+					be.BalancingMode = "UTILIZATION"
+					be.MaxRatePerEndpoint = 0
+					be.CapacityScaler = 0.5
+				}
+			}),
+			want: []BackendDrift{{BackendService: "test", Group: zone1, Kind: driftBackendModified, Fields: []string{"balancingMode", "maxRatePerEndpoint"}}},
+		},
+		{
+			name:     "unknown backend of the service's NEG",
+			actual:   statusBasicWithNEGs,
+			intended: statusBasicWithNEGs,
+			backends: append(driftTestBackends(nil), &compute.Backend{Group: unknown}),
+			want:     []BackendDrift{{BackendService: "test", Group: unknown, Kind: driftUnknownBackend}},
+		},
+		{
+			name:     "changed configuration is not drift",
+			actual:   statusBasicWithNEGs,
+			intended: statusValueChangeWithNEGs,
+			backends: driftTestBackends(nil),
+		},
+		{
+			name:     "new backend service is not drift",
+			actual:   statusInitial,
+			intended: statusBasicWithNEGs,
+			backends: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectDrift(fakeProject, tt.actual, tt.intended, "80", "test", &compute.BackendService{Name: "test", Backends: tt.backends})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detectDrift() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileBackendsDriftPolicy(t *testing.T) {
+	zone1 := getGroup(fakeProject, "zone1", fakeNeg)
+	unknown := getGroup(fakeProject, "zone9", fakeNeg)
+	tests := []struct {
+		policy     string
+		wantPatch  bool
+		wantGroups []string
+	}{
+		{policy: driftPolicyEnforce, wantPatch: true, wantGroups: []string{zone1, getGroup(fakeProject, "zone2", fakeNeg)}},
+		{policy: driftPolicyReportOnly},
+		{policy: driftPolicyIgnore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			var patched *compute.BackendService
+			s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+				res.Header().Set("Content-Type", "application/json")
+				switch {
+				case req.Method == http.MethodGet && strings.Contains(req.URL.Path, "backendServices"):
+					// zone1 was removed, and a backend in an unknown zone added
+					backends := append(driftTestBackends(nil)[1:], &compute.Backend{Group: unknown, BalancingMode: "RATE", MaxRatePerEndpoint: 100})
+					json.NewEncoder(res).Encode(compute.BackendService{Name: "test", Backends: backends})
+				case req.Method == http.MethodPatch:
+					patched = &compute.BackendService{}
+					json.NewDecoder(req.Body).Decode(patched)
+					json.NewEncoder(res).Encode(compute.Operation{Name: "op", Status: computeOperationStatusDone})
+				default:
+					t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+				}
+			}))
+			defer s.Close()
+			cs, err := compute.NewService(context.Background(), option.WithEndpoint(s.URL), option.WithoutAuthentication())
+			if err != nil {
+				t.Fatalf("Failed to instantiate compute service: %v", err)
+			}
+			bc := ProdBackendController{project: fakeProject, s: cs}
+
+			intended := statusBasicWithNEGs
+			intended.driftPolicy = tt.policy
+			drift, err := bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, intended, false)
+			if err != nil {
+				t.Fatalf("ReconcileBackends() got err: %v", err)
+			}
+			kinds := make([]string, 0, len(drift))
+			for _, d := range drift {
+				kinds = append(kinds, d.Kind)
+			}
+			slices.Sort(kinds)
+			if want := []string{driftBackendRemoved, driftUnknownBackend}; !reflect.DeepEqual(kinds, want) {
+				t.Errorf("ReconcileBackends() got drift %+v, want kinds %v", drift, want)
+			}
+			if (patched != nil) != tt.wantPatch {
+				t.Fatalf("ReconcileBackends() patched: %v, want %v", patched != nil, tt.wantPatch)
+			}
+			if patched != nil {
+				var groups []string
+				for _, be := range patched.Backends {
+					groups = append(groups, be.Group)
+				}
+				slices.Sort(groups)
+				if !reflect.DeepEqual(groups, tt.wantGroups) {
+					t.Errorf("ReconcileBackends() patched backends %v, want %v", groups, tt.wantGroups)
+				}
+			}
+		})
+	}
+}
+
+func TestReportDrift(t *testing.T) {
+	ctx := context.Background()
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "svc"}}
+	r := newTestReconciler(svc)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+	r.MetricDriftDetected = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "drift_detected_total"}, []string{"namespace", "service", "kind"})
+	removed := BackendDrift{BackendService: "test", Group: getGroup(fakeProject, "zone1", fakeNeg), Kind: driftBackendRemoved}
+	modified := BackendDrift{BackendService: "test", Region: "europe-west4", Group: getGroup(fakeProject, "zone2", fakeNeg), Kind: driftBackendModified, Fields: []string{"maxRatePerEndpoint", "capacityScaler"}}
+
+	// Drift left alone is only counted and announced once.
+	for range 3 {
+		r.reportDrift(ctx, logr.Discard(), svc, driftPolicyReportOnly, []BackendDrift{removed})
+	}
+	r.reportDrift(ctx, logr.Discard(), svc, driftPolicyReportOnly, []BackendDrift{removed, modified})
+	if got := len(recorder.Events); got != 2 {
+		t.Errorf("reportDrift() recorded %d events, want 2", got)
+	}
+	for kind, want := range map[string]float64{driftBackendRemoved: 1, driftBackendModified: 1} {
+		if got := testutil.ToFloat64(r.MetricDriftDetected.WithLabelValues("ns", "svc", kind)); got != want {
+			t.Errorf("drift detected of kind %s = %g, want %g", kind, got, want)
+		}
+	}
+	cond := meta.FindStatusCondition(svc.Status.Conditions, conditionDrifted)
+	if cond == nil || cond.Reason != "DriftReported" || !strings.Contains(cond.Message, modified.String()) {
+		t.Errorf("Drifted condition = %+v, want both drifts reported", cond)
+	}
+
+	// Drift found again after it was gone is counted again.
+	r.reportDrift(ctx, logr.Discard(), svc, driftPolicyReportOnly, nil)
+	r.reportDrift(ctx, logr.Discard(), svc, driftPolicyReportOnly, []BackendDrift{removed})
+	if got := testutil.ToFloat64(r.MetricDriftDetected.WithLabelValues("ns", "svc", driftBackendRemoved)); got != 2 {
+		t.Errorf("drift detected of kind %s = %g, want 2", driftBackendRemoved, got)
+	}
+}
+
@@ -0,0 +1,176 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// weightsByEndpoints returns true if any backend service is weighted by the
+// ready endpoints of the service
+func (c AutonegConfig) weightsByEndpoints() bool {
+	for _, cfgs := range c.BackendServices {
+		for _, cfg := range cfgs {
+			if cfg.WeightByEndpoints {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// countsEndpoints returns true if the ready endpoints of the service are
+// needed, to weight backends or to detach empty zones
+func (c AutonegConfig) countsEndpoints() bool {
+	for _, cfgs := range c.BackendServices {
+		for _, cfg := range cfgs {
+			if cfg.WeightByEndpoints || cfg.DetachEmptyZones {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// attached returns true if the backend of the NEG is attached to the backend
+// service, i.e. its zone has ready endpoints or empty zones are not detached.
+func (s AutonegStatus) attached(name string, port string, group string) bool {
+	cfg := s.AutonegConfig.BackendServices[port][name]
+	return !cfg.DetachEmptyZones || s.Endpoints[groupZone(group)] > 0
+}
+
+// weightsByEndpoints returns true if any backend service of the annotation
+// is weighted by the ready endpoints of the service
+func (c AutonegConfigTemp) weightsByEndpoints() bool {
+	for _, cfgs := range c.BackendServices {
+		for _, cfg := range cfgs {
+			if cfg.WeightByEndpoints {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readyEndpoints counts the ready endpoints of the service by zone, from its
+// EndpointSlices of the primary IP family of the service.
+func (r *ServiceReconciler) readyEndpoints(ctx context.Context, svc *corev1.Service) (map[string]int, error) {
+	slices := &discoveryv1.EndpointSliceList{}
+	if err := r.List(ctx, slices, client.InNamespace(svc.Namespace), client.MatchingLabels{discoveryv1.LabelServiceName: svc.Name}); err != nil {
+		return nil, err
+	}
+	addressType := discoveryv1.AddressTypeIPv4
+	if len(svc.Spec.IPFamilies) > 0 && svc.Spec.IPFamilies[0] == corev1.IPv6Protocol {
+		addressType = discoveryv1.AddressTypeIPv6
+	}
+	counted := map[string]bool{}
+	endpoints := map[string]int{}
+	for _, slice := range slices.Items {
+		if slice.AddressType != addressType {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if ep.Zone == nil || len(ep.Addresses) == 0 || (ep.Conditions.Ready != nil && !*ep.Conditions.Ready) {
+				continue
+			}
+			// Endpoints can be in more than one slice while they are
+			// updated.
+			if counted[ep.Addresses[0]] {
+				continue
+			}
+			counted[ep.Addresses[0]] = true
+			endpoints[*ep.Zone]++
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, nil
+	}
+	return endpoints, nil
+}
+
+// readyZones returns the zones with ready endpoints of an EndpointSlice
+func readyZones(slice *discoveryv1.EndpointSlice) map[string]struct{} {
+	zones := map[string]struct{}{}
+	for _, ep := range slice.Endpoints {
+		if ep.Zone != nil && (ep.Conditions.Ready == nil || *ep.Conditions.Ready) {
+			zones[*ep.Zone] = struct{}{}
+		}
+	}
+	return zones
+}
+
+// endpointZonesChanged passes EndpointSlice updates which change the zones
+// with ready endpoints
+func endpointZonesChanged(e event.UpdateEvent) bool {
+	oldSlice, ok := e.ObjectOld.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return true
+	}
+	newSlice, ok := e.ObjectNew.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return true
+	}
+	return !maps.Equal(readyZones(oldSlice), readyZones(newSlice))
+}
+
+// endpointZonesService enqueues the service of an EndpointSlice, if it is
+// managed by autoneg.
+func (r *ServiceReconciler) endpointZonesService(ctx context.Context, obj client.Object) []reconcile.Request {
+	name, ok := obj.GetLabels()[discoveryv1.LabelServiceName]
+	if !ok {
+		return nil
+	}
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}
+	svc := &corev1.Service{}
+	if err := r.Get(ctx, key, svc); err != nil {
+		return nil
+	}
+	_, configured := svc.Annotations[autonegAnnotation]
+	_, reconciled := svc.Annotations[autonegStatusAnnotation]
+	if !configured && !reconciled {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: key}}
+}
+
+// endpointSliceService enqueues the service of an EndpointSlice, if it has
+// backend services weighted by endpoints.
+func (r *ServiceReconciler) endpointSliceService(ctx context.Context, obj client.Object) []reconcile.Request {
+	name, ok := obj.GetLabels()[discoveryv1.LabelServiceName]
+	if !ok {
+		return nil
+	}
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}
+	svc := &corev1.Service{}
+	if err := r.Get(ctx, key, svc); err != nil {
+		return nil
+	}
+	var config AutonegConfigTemp
+	if err := json.Unmarshal([]byte(svc.Annotations[autonegAnnotation]), &config); err != nil || !config.weightsByEndpoints() {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: key}}
+}
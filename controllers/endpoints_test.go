@@ -0,0 +1,214 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestReadyEndpoints(t *testing.T) {
+	ctx := context.Background()
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "ns",
+			Name:        "svc",
+			Annotations: map[string]string{autonegAnnotation: `{"backend_services":{"80":[{"name":"test","max_rate_per_endpoint":100,"weight_by_endpoints":true}]}}`},
+		},
+	}
+	r := newTestReconciler(svc)
+	endpoint := func(address, zone string, ready bool) discoveryv1.Endpoint {
+		return discoveryv1.Endpoint{Addresses: []string{address}, Zone: ptr.To(zone), Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(ready)}}
+	}
+	slices := []*discoveryv1.EndpointSlice{
+		{
+			ObjectMeta:  metav1.ObjectMeta{Namespace: "ns", Name: "svc-1", Labels: map[string]string{discoveryv1.LabelServiceName: "svc"}},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints: []discoveryv1.Endpoint{
+				endpoint("10.0.0.1", "zone1", true),
+				endpoint("10.0.0.2", "zone1", true),
+				endpoint("10.0.0.3", "zone1", false),
+				endpoint("10.0.1.1", "zone2", true),
+			},
+		},
+		{
+			// An endpoint moving between slices is counted once.
+			ObjectMeta:  metav1.ObjectMeta{Namespace: "ns", Name: "svc-2", Labels: map[string]string{discoveryv1.LabelServiceName: "svc"}},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   []discoveryv1.Endpoint{endpoint("10.0.0.1", "zone1", true)},
+		},
+		{
+			ObjectMeta:  metav1.ObjectMeta{Namespace: "ns", Name: "svc-v6", Labels: map[string]string{discoveryv1.LabelServiceName: "svc"}},
+			AddressType: discoveryv1.AddressTypeIPv6,
+			Endpoints:   []discoveryv1.Endpoint{endpoint("fd00::1", "zone1", true)},
+		},
+		{
+			ObjectMeta:  metav1.ObjectMeta{Namespace: "ns", Name: "other", Labels: map[string]string{discoveryv1.LabelServiceName: "other"}},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   []discoveryv1.Endpoint{endpoint("10.0.2.1", "zone1", true)},
+		},
+	}
+	for _, slice := range slices {
+		if err := r.Create(ctx, slice); err != nil {
+			t.Fatalf("Create() got err: %v", err)
+		}
+	}
+
+	got, err := r.readyEndpoints(ctx, svc)
+	if err != nil {
+		t.Fatalf("readyEndpoints() got err: %v", err)
+	}
+	if want := map[string]int{"zone1": 2, "zone2": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("readyEndpoints() = %v, want %v", got, want)
+	}
+
+	if got := r.endpointSliceService(ctx, slices[0]); len(got) != 1 || got[0].Name != "svc" {
+		t.Errorf("endpointSliceService() = %v, want the service", got)
+	}
+	if got := r.endpointSliceService(ctx, slices[3]); len(got) != 0 {
+		t.Errorf("endpointSliceService() of a service without autoneg = %v, want none", got)
+	}
+}
+
+func TestBackendWeightByEndpoints(t *testing.T) {
+	rate := AutonegStatus{
+		AutonegConfig: AutonegConfig{BackendServices: map[string]map[string]AutonegNEGConfig{
+			"80": {"test": {Name: "test", Rate: 100, WeightByEndpoints: true}},
+		}},
+		NEGStatus: negStatus,
+		Endpoints: map[string]int{"zone1": 2, "zone2": 20},
+	}
+	connections := rate
+	connections.AutonegConfig = AutonegConfig{BackendServices: map[string]map[string]AutonegNEGConfig{
+		"80": {"test": {Name: "test", Connections: 10, WeightByEndpoints: true}},
+	}}
+	tests := []struct {
+		name   string
+		status AutonegStatus
+		zone   string
+		want   compute.Backend
+	}{
+		{
+			name:   "rate",
+			status: rate,
+			zone:   "zone1",
+			want:   compute.Backend{BalancingMode: "RATE", MaxRate: 200, CapacityScaler: 1},
+		},
+		{
+			name:   "rate of larger zone",
+			status: rate,
+			zone:   "zone2",
+			want:   compute.Backend{BalancingMode: "RATE", MaxRate: 2000, CapacityScaler: 1},
+		},
+		{
+			name:   "connections",
+			status: connections,
+			zone:   "zone2",
+			want:   compute.Backend{BalancingMode: "CONNECTION", MaxConnections: 200, CapacityScaler: 1},
+		},
+		{
+			name:   "zone without ready endpoints",
+			status: rate,
+			zone:   "zone3",
+			want:   compute.Backend{BalancingMode: "RATE", MaxRate: 100, CapacityScaler: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := getGroup(fakeProject, tt.zone, fakeNeg)
+			tt.want.Group = group
+			if got := tt.status.Backend("test", "80", group); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Backend() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	invalid := rate.AutonegConfig
+	invalid.BackendServices = map[string]map[string]AutonegNEGConfig{
+		"80": {"test": {Name: "test", WeightByEndpoints: true, CustomMetrics: []AutonegCustomMetric{{Name: "orca.cpu_utilization", MaxUtilization: 0.8}}}},
+	}
+	if err := validateConfig(invalid); err == nil {
+		t.Errorf("validateConfig() with custom metrics weighted by endpoints got no error")
+	}
+}
+
+func TestReconcileStatusDetachEmptyZones(t *testing.T) {
+	detached := AutonegStatus{
+		AutonegConfig: AutonegConfig{BackendServices: map[string]map[string]AutonegNEGConfig{
+			"80": {"test": {Name: "test", Rate: 100, DetachEmptyZones: true}},
+		}},
+		NEGStatus: negStatus,
+		Endpoints: map[string]int{"zone1": 3},
+	}
+	zone1 := getGroup(fakeProject, "zone1", fakeNeg)
+	zone2 := getGroup(fakeProject, "zone2", fakeNeg)
+	groups := func(backends []compute.Backend) []string {
+		var groups []string
+		for _, be := range backends {
+			groups = append(groups, be.Group)
+		}
+		return groups
+	}
+
+	// The empty zone is detached from a backend service which had both.
+	removes, upserts := ReconcileStatus(logr.Discard(), fakeProject, statusBasicWithNEGs, detached)
+	if got, want := groups(upserts["80"]["test"].backends), []string{zone1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReconcileStatus() upserts %v, want %v", got, want)
+	}
+	if got, want := groups(removes["80"]["test"].backends), []string{zone2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReconcileStatus() removes %v, want %v", got, want)
+	}
+
+	// The zone is attached again once it has ready endpoints.
+	ready := detached
+	ready.Endpoints = map[string]int{"zone1": 3, "zone2": 1}
+	removes, upserts = ReconcileStatus(logr.Discard(), fakeProject, detached, ready)
+	if got, want := groups(upserts["80"]["test"].backends), []string{zone1, zone2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReconcileStatus() upserts %v, want %v", got, want)
+	}
+	if got := removes["80"]["test"].backends; len(got) != 0 {
+		t.Errorf("ReconcileStatus() removes %v, want none", groups(got))
+	}
+}
+
+func TestEndpointZonesChanged(t *testing.T) {
+	slice := func(zones ...string) *discoveryv1.EndpointSlice {
+		s := &discoveryv1.EndpointSlice{}
+		for _, zone := range zones {
+			s.Endpoints = append(s.Endpoints, discoveryv1.Endpoint{Addresses: []string{"10.0.0.1"}, Zone: ptr.To(zone)})
+		}
+		return s
+	}
+	if endpointZonesChanged(event.UpdateEvent{ObjectOld: slice("zone1"), ObjectNew: slice("zone1", "zone1")}) {
+		t.Errorf("endpointZonesChanged() for a new endpoint in the same zone = true, want false")
+	}
+	if !endpointZonesChanged(event.UpdateEvent{ObjectOld: slice("zone1"), ObjectNew: slice("zone1", "zone2")}) {
+		t.Errorf("endpointZonesChanged() for an endpoint in a new zone = false, want true")
+	}
+	if !endpointZonesChanged(event.UpdateEvent{ObjectOld: slice("zone1", "zone2"), ObjectNew: slice("zone2")}) {
+		t.Errorf("endpointZonesChanged() for the last endpoint leaving a zone = false, want true")
+	}
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// Classes of compute errors, used as condition reasons and to decide
+// whether a failed change is retried.
+const (
+	errorClassQuotaExceeded    = "QuotaExceeded"
+	errorClassResourceInUse    = "ResourceInUse"
+	errorClassInvalidField     = "InvalidField"
+	errorClassPermissionDenied = "PermissionDenied"
+	errorClassNotFound         = "NotFound"
+	errorClassConflict         = "PreconditionFailed"
+	errorClassServerError      = "ServerError"
+	errorClassUnknown          = "Unknown"
+)
+
+// rateLimitReasons are the googleapi error reasons of quota errors, which
+// may be returned with a 403 status code
+var rateLimitReasons = []string{"rateLimitExceeded", "userRateLimitExceeded", "quotaExceeded"}
+
+// operationErrorClasses maps compute operation error codes to error classes
+var operationErrorClasses = map[string]string{
+	"QUOTA_EXCEEDED":                      errorClassQuotaExceeded,
+	"RATE_LIMIT_EXCEEDED":                 errorClassQuotaExceeded,
+	"RESOURCE_IN_USE_BY_ANOTHER_RESOURCE": errorClassResourceInUse,
+	"RESOURCE_NOT_READY":                  errorClassResourceInUse,
+	"RESOURCE_OPERATION_RATE_EXCEEDED":    errorClassResourceInUse,
+	"CONDITION_NOT_MET":                   errorClassResourceInUse,
+	"INVALID_FIELD_VALUE":                 errorClassInvalidField,
+	"INVALID_USAGE":                       errorClassInvalidField,
+	"REQUIRED_FIELD_MISSING":              errorClassInvalidField,
+	"BAD_REQUEST":                         errorClassInvalidField,
+	"PERMISSIONS_ERROR":                   errorClassPermissionDenied,
+	"FORBIDDEN":                           errorClassPermissionDenied,
+}
+
+// errOperationFailed holds the details of a compute operation which
+// finished with errors
+type errOperationFailed struct {
+	Name     string
+	Id       uint64
+	Errors   []*compute.OperationErrorErrors
+	Warnings []*compute.OperationWarnings
+}
+
+func newErrOperationFailed(op *compute.Operation) *errOperationFailed {
+	e := &errOperationFailed{
+		Name:     op.Name,
+		Id:       op.Id,
+		Warnings: op.Warnings,
+	}
+	if op.Error != nil {
+		e.Errors = op.Error.Errors
+	}
+	return e
+}
+
+func (e *errOperationFailed) Error() string {
+	var details []string
+	for _, oe := range e.Errors {
+		detail := fmt.Sprintf("%s: %s", oe.Code, oe.Message)
+		if oe.Location != "" {
+			detail = fmt.Sprintf("%s (location: %s)", detail, oe.Location)
+		}
+		details = append(details, detail)
+	}
+	for _, w := range e.Warnings {
+		details = append(details, fmt.Sprintf("warning %s: %s", w.Code, w.Message))
+	}
+	name := e.Name
+	if name == "" {
+		name = fmt.Sprintf("%d", e.Id)
+	}
+	if len(details) == 0 {
+		return fmt.Sprintf("operation %s failed", name)
+	}
+	return fmt.Sprintf("operation %s failed: %s", name, strings.Join(details, "; "))
+}
+
+// Class returns the error class of the first error of the operation
+// which has a known class.
+func (e *errOperationFailed) Class() string {
+	for _, oe := range e.Errors {
+		if class, ok := operationErrorClasses[oe.Code]; ok {
+			return class
+		}
+	}
+	return errorClassUnknown
+}
+
+// Retryable returns true if retrying the change may succeed without
+// changing the configuration. Permissions may be granted at any time, so
+// permission errors are retryable.
+func (e *errOperationFailed) Retryable() bool {
+	return e.Class() != errorClassInvalidField
+}
+
+// keysAndValues returns the structured error details for logging.
+func (e *errOperationFailed) keysAndValues() []any {
+	codes := make([]string, 0, len(e.Errors))
+	for _, oe := range e.Errors {
+		codes = append(codes, oe.Code)
+	}
+	return []any{"operation", e.Name, "operationId", e.Id, "class", e.Class(), "codes", codes}
+}
+
+// classifyError returns the error class of a failed compute API call and
+// whether the error is permanent, i.e. retrying it without changing the
+// configuration will not succeed.
+func classifyError(err error) (class string, permanent bool) {
+	var opErr *errOperationFailed
+	if errors.As(err, &opErr) {
+		return opErr.Class(), !opErr.Retryable()
+	}
+	var nf *errNotFound
+	if errors.As(err, &nf) {
+		return errorClassNotFound, true
+	}
+	var pd *errPermissionDenied
+	if errors.As(err, &pd) {
+		return errorClassPermissionDenied, false
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		for _, item := range apiErr.Errors {
+			for _, reason := range rateLimitReasons {
+				if item.Reason == reason {
+					return errorClassQuotaExceeded, false
+				}
+			}
+		}
+		switch {
+		case apiErr.Code == http.StatusBadRequest:
+			return errorClassInvalidField, true
+		case apiErr.Code == http.StatusForbidden:
+			return errorClassPermissionDenied, false
+		case apiErr.Code == http.StatusNotFound:
+			return errorClassNotFound, true
+		case apiErr.Code == http.StatusPreconditionFailed:
+			return errorClassConflict, false
+		case apiErr.Code == http.StatusTooManyRequests:
+			return errorClassQuotaExceeded, false
+		case apiErr.Code >= http.StatusInternalServerError:
+			return errorClassServerError, false
+		}
+	}
+	return errorClassUnknown, false
+}
+
+// isRetryable returns false for errors which are known to persist until
+// the configuration changes.
+func isRetryable(err error) bool {
+	_, permanent := classifyError(err)
+	return !permanent
+}
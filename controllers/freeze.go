@@ -0,0 +1,52 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// errMutationsFrozen is returned by ReconcileBackends when changes of
+// backend services were skipped because the controller config freezes them
+type errMutationsFrozen struct {
+	BackendServices []string
+}
+
+func (e *errMutationsFrozen) Error() string {
+	return fmt.Sprintf("mutations frozen: changes of backend services %s skipped", strings.Join(e.BackendServices, ", "))
+}
+
+// waitForUnfreeze records changes skipped by the freeze as a warning event
+// and the Synced condition. The intended status is not stored, so the
+// changes are applied once the freeze is lifted, which reconciles all
+// services. A deleted service keeps its finalizer until then.
+func (r *ServiceReconciler) waitForUnfreeze(ctx context.Context, logger logr.Logger, svc *corev1.Service, errorKey string, frozenErr *errMutationsFrozen) (reconcile.Result, error) {
+	logger.Info("Mutations are frozen, skipping changes", "backendServices", frozenErr.BackendServices)
+	r.Recorder.Event(svc, "Warning", "MutationsFrozen", frozenErr.Error())
+	message := fmt.Sprintf("Changes are frozen by the %s key of the controller config", controllerConfigFreeze)
+	if err := r.setCondition(ctx, svc, conditionSynced, metav1.ConditionFalse, "MutationsFrozen", message); err != nil {
+		logger.Error(err, "Failed to update service status")
+	}
+	return r.reconcileResult(ctx, logger, svc, errorKey, nil)
+}
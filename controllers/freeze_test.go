@@ -0,0 +1,116 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestReconcileBackendsFrozen(t *testing.T) {
+	patches := 0
+	s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if req.Method == http.MethodPatch {
+			patches++
+			json.NewEncoder(res).Encode(compute.Operation{Name: "op", Status: computeOperationStatusDone})
+			return
+		}
+		// The backends were removed out-of-band.
+		json.NewEncoder(res).Encode(compute.BackendService{Name: "test"})
+	}))
+	defer s.Close()
+	cs, err := compute.NewService(context.Background(), option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to instantiate compute service: %v", err)
+	}
+	bc := ProdBackendController{project: fakeProject, s: cs}
+
+	intended := statusBasicWithNEGs
+	intended.driftPolicy = driftPolicyEnforce
+	intended.frozen = true
+	drift, err := bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, intended, false)
+	var frozenErr *errMutationsFrozen
+	if !errors.As(err, &frozenErr) {
+		t.Fatalf("ReconcileBackends() got err %v, want mutations frozen", err)
+	}
+	if want := []string{"test"}; !reflect.DeepEqual(frozenErr.BackendServices, want) {
+		t.Errorf("ReconcileBackends() skipped backend services %v, want %v", frozenErr.BackendServices, want)
+	}
+	if patches != 0 {
+		t.Errorf("ReconcileBackends() patched %d times while frozen, want 0", patches)
+	}
+	// Drift is still detected.
+	if len(drift) != 2 {
+		t.Errorf("ReconcileBackends() got drift %+v, want 2 removed backends", drift)
+	}
+}
+
+func TestReconcileFrozen(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	r := newTestReconciler(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   key.Namespace,
+			Name:        key.Name,
+			Annotations: map[string]string{autonegAnnotation: validConfig},
+		},
+	})
+	bc := &recordingBackendController{err: &errMutationsFrozen{BackendServices: []string{"http-be"}}}
+	r.BackendController = bc
+	r.ControllerConfigMap = types.NamespacedName{Namespace: "autoneg-system", Name: "autoneg-controller-config"}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: r.ControllerConfigMap.Namespace, Name: r.ControllerConfigMap.Name},
+		Data:       map[string]string{controllerConfigFreeze: "true"},
+	}
+	if err := r.Create(ctx, cm); err != nil {
+		t.Fatalf("Create() got err: %v", err)
+	}
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+	if !bc.intended[0].frozen {
+		t.Errorf("ReconcileBackends() got an intended status which is not frozen")
+	}
+	svc := &corev1.Service{}
+	if err := r.Get(ctx, key, svc); err != nil {
+		t.Fatalf("Get() got err: %v", err)
+	}
+	if c := meta.FindStatusCondition(svc.Status.Conditions, conditionSynced); c == nil || c.Status != metav1.ConditionFalse || c.Reason != "MutationsFrozen" {
+		t.Errorf("Synced condition = %+v, want False with reason MutationsFrozen", c)
+	}
+	if _, ok := svc.Annotations[autonegStatusAnnotation]; ok {
+		t.Errorf("Reconcile() stored the intended status while frozen")
+	}
+	if r.ErrorCount[key.String()] != 0 {
+		t.Errorf("Reconcile() counted the freeze as an error")
+	}
+}
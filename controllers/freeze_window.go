@@ -0,0 +1,134 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// conditionChangesDeferred reports backend service changes waiting for
+	// the end of a freeze window.
+	conditionChangesDeferred = "ChangesDeferred"
+	// maxFreezeWindowDuration bounds the duration of a freeze window
+	maxFreezeWindowDuration = 7 * 24 * time.Hour
+)
+
+// FreezeWindow is a recurring window during which backend service changes
+// other than removals of backends are deferred
+type FreezeWindow struct {
+	Name string `json:"name,omitempty"`
+	// Schedule is the five field cron schedule of the start of the window
+	Schedule string `json:"schedule"`
+	// Duration is the length of the window, e.g. 6h30m
+	Duration string `json:"duration"`
+	// Timezone is the IANA time zone of the schedule, UTC by default
+	Timezone string `json:"timezone,omitempty"`
+	// Namespaces limits the window to services in these namespaces, it
+	// applies to all services by default
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	schedule cronSchedule
+	duration time.Duration
+	location *time.Location
+}
+
+// FreezeWindows are the freeze windows of the controller config
+type FreezeWindows []FreezeWindow
+
+// parseFreezeWindows parses the freeze windows of the controller config
+func parseFreezeWindows(value string) (FreezeWindows, error) {
+	var windows FreezeWindows
+	if err := json.Unmarshal([]byte(value), &windows); err != nil {
+		return nil, fmt.Errorf("%w: %s is not a list of windows: %v", errConfigInvalid, controllerConfigFreezeWindows, err)
+	}
+	for i := range windows {
+		w := &windows[i]
+		var err error
+		if w.schedule, err = parseCron(w.Schedule); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errConfigInvalid, controllerConfigFreezeWindows, err)
+		}
+		if w.duration, err = time.ParseDuration(w.Duration); err != nil || w.duration <= 0 || w.duration > maxFreezeWindowDuration {
+			return nil, fmt.Errorf("%w: %s: duration %q must be positive and at most %s", errConfigInvalid, controllerConfigFreezeWindows, w.Duration, maxFreezeWindowDuration)
+		}
+		if w.location, err = time.LoadLocation(w.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: %s: unknown timezone %q", errConfigInvalid, controllerConfigFreezeWindows, w.Timezone)
+		}
+	}
+	return windows, nil
+}
+
+// end returns the end of the window if it is open at the given time
+func (w FreezeWindow) end(now time.Time) (time.Time, bool) {
+	// The latest start within the duration determines the end.
+	start, ok := w.schedule.prev(now.In(w.location), now.Add(-w.duration))
+	if !ok {
+		return time.Time{}, false
+	}
+	return start.Add(w.duration), true
+}
+
+// active returns the open window of the namespace which ends last, if any
+func (ws FreezeWindows) active(namespace string, now time.Time) (name string, end time.Time, ok bool) {
+	for _, w := range ws {
+		if len(w.Namespaces) > 0 && !slices.Contains(w.Namespaces, namespace) {
+			continue
+		}
+		if e, open := w.end(now); open && e.After(end) {
+			name, end, ok = w.Name, e, true
+		}
+	}
+	return
+}
+
+// errChangesDeferred is returned by ReconcileBackends when changes of
+// backend services were deferred by a freeze window
+type errChangesDeferred struct {
+	BackendServices []string
+}
+
+func (e *errChangesDeferred) Error() string {
+	return fmt.Sprintf("changes of backend services %s deferred", strings.Join(e.BackendServices, ", "))
+}
+
+// deferChanges records changes deferred by a freeze window as an event and
+// the ChangesDeferred condition. The intended status is not stored, so the
+// changes are applied when the service is reconciled after the window.
+func (r *ServiceReconciler) deferChanges(ctx context.Context, logger logr.Logger, svc *corev1.Service, errorKey string, deferredErr *errChangesDeferred, window string, end time.Time) (reconcile.Result, error) {
+	if window == "" {
+		window = "unnamed"
+	}
+	message := fmt.Sprintf("Changes of backend services %s are deferred by freeze window %s until %s", strings.Join(deferredErr.BackendServices, ", "), window, end.UTC().Format(time.RFC3339))
+	logger.Info("Changes deferred by freeze window", "window", window, "end", end, "backendServices", deferredErr.BackendServices)
+	r.Recorder.Event(svc, "Normal", "ChangesDeferred", message)
+	if err := r.setCondition(ctx, svc, conditionChangesDeferred, metav1.ConditionTrue, "FreezeWindow", message); err != nil {
+		logger.Error(err, "Failed to update service status")
+	}
+	res, err := r.reconcileResult(ctx, logger, svc, errorKey, nil)
+	res.RequeueAfter = time.Until(end) + time.Second
+	return res, err
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestFreezeWindowsActive(t *testing.T) {
+	windows, err := parseFreezeWindows(`[
+		{"name":"trading","schedule":"30 9 * * 1-5","duration":"6h30m","timezone":"America/New_York","namespaces":["trading"]},
+		{"name":"weekend","schedule":"0 0 * * 6","duration":"48h"},
+		{"name":"month start","schedule":"0 0 1 * *","duration":"168h","namespaces":["billing"]}
+	]`)
+	if err != nil {
+		t.Fatalf("parseFreezeWindows() got err: %v", err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation() got err: %v", err)
+	}
+	// 2026-03-02 is a Monday.
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2026, time.March, 2, hour, minute, 0, 0, newYork)
+	}
+	tests := []struct {
+		name      string
+		namespace string
+		now       time.Time
+		want      string
+		wantEnd   time.Time
+	}{
+		{"before trading hours", "trading", monday(9, 29), "", time.Time{}},
+		{"trading hours", "trading", monday(12, 0), "trading", monday(16, 0)},
+		{"other namespace", "default", monday(12, 0), "", time.Time{}},
+		{"after trading hours", "trading", monday(16, 0), "", time.Time{}},
+		{"weekend", "default", time.Date(2026, time.March, 8, 23, 0, 0, 0, time.UTC), "weekend", time.Date(2026, time.March, 9, 0, 0, 0, 0, time.UTC)},
+		{"month start", "billing", time.Date(2026, time.March, 6, 23, 59, 0, 0, time.UTC), "month start", time.Date(2026, time.March, 8, 0, 0, 0, 0, time.UTC)},
+		{"after month start", "billing", time.Date(2026, time.March, 8, 0, 0, 0, 0, time.UTC), "weekend", time.Date(2026, time.March, 9, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, end, ok := windows.active(tt.namespace, tt.now)
+			if name != tt.want || ok != (tt.want != "") || !end.Equal(tt.wantEnd) {
+				t.Errorf("active() = %q, %v, %v, want %q ending %v", name, end, ok, tt.want, tt.wantEnd)
+			}
+		})
+	}
+
+	for _, value := range []string{
+		`{}`,
+		`[{"schedule":"30 9 * *","duration":"1h"}]`,
+		`[{"schedule":"30 9 * * *","duration":"0s"}]`,
+		`[{"schedule":"30 9 * * *","duration":"1h","timezone":"Mars/Olympus_Mons"}]`,
+	} {
+		if _, err := parseFreezeWindows(value); err == nil {
+			t.Errorf("parseFreezeWindows(%s) got no error", value)
+		}
+	}
+}
+
+func TestReconcileBackendsDeferChanges(t *testing.T) {
+	zone1 := getGroup(fakeProject, "zone1", fakeNeg)
+	var patched []string
+	s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if req.Method == http.MethodPatch {
+			var body compute.BackendService
+			json.NewDecoder(req.Body).Decode(&body)
+			patched = nil
+			for _, be := range body.Backends {
+				patched = append(patched, be.Group)
+			}
+			json.NewEncoder(res).Encode(compute.Operation{Name: "op", Status: computeOperationStatusDone})
+			return
+		}
+		json.NewEncoder(res).Encode(compute.BackendService{Name: "test", Backends: driftTestBackends(nil)})
+	}))
+	defer s.Close()
+	cs, err := compute.NewService(context.Background(), option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to instantiate compute service: %v", err)
+	}
+	bc := ProdBackendController{project: fakeProject, s: cs}
+
+	// The NEG of zone2 is gone and the rate changed: the backend is removed,
+	// the rate of the remaining one is deferred.
+	intended := statusBasicWithNEGs
+	intended.NEGStatus = NEGStatus{NEGs: negStatus.NEGs, Zones: []string{"zone1"}}
+	intended.AutonegConfig = AutonegConfig{BackendServices: map[string]map[string]AutonegNEGConfig{
+		"80": {"test": {Name: "test", Rate: 200}},
+	}}
+	intended.deferChanges = true
+	_, err = bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, intended, false)
+	var deferredErr *errChangesDeferred
+	if !errors.As(err, &deferredErr) || !reflect.DeepEqual(deferredErr.BackendServices, []string{"test"}) {
+		t.Fatalf("ReconcileBackends() got err %v, want changes of test deferred", err)
+	}
+	if want := []string{zone1}; !reflect.DeepEqual(patched, want) {
+		t.Errorf("ReconcileBackends() patched backends %v, want %v", patched, want)
+	}
+
+	// Only removing the backend is not deferred.
+	intended.AutonegConfig = statusBasicWithNEGs.AutonegConfig
+	patched = nil
+	if _, err = bc.ReconcileBackends(context.Background(), statusBasicWithNEGs, intended, false); err != nil {
+		t.Fatalf("ReconcileBackends() got err: %v", err)
+	}
+	if want := []string{zone1}; !reflect.DeepEqual(patched, want) {
+		t.Errorf("ReconcileBackends() patched backends %v, want %v", patched, want)
+	}
+}
+
+func TestReconcileChangesDeferred(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	r := newTestReconciler(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   key.Namespace,
+			Name:        key.Name,
+			Annotations: map[string]string{autonegAnnotation: validConfig},
+		},
+	})
+	bc := &recordingBackendController{err: &errChangesDeferred{BackendServices: []string{"http-be"}}}
+	r.BackendController = bc
+	r.ControllerConfigMap = types.NamespacedName{Namespace: "autoneg-system", Name: "autoneg-controller-config"}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: r.ControllerConfigMap.Namespace, Name: r.ControllerConfigMap.Name},
+		Data:       map[string]string{controllerConfigFreezeWindows: `[{"name":"always","schedule":"* * * * *","duration":"1h","namespaces":["ns"]}]`},
+	}
+	if err := r.Create(ctx, cm); err != nil {
+		t.Fatalf("Create() got err: %v", err)
+	}
+	req := ctrl.Request{NamespacedName: key}
+
+	res, err := r.Reconcile(ctx, req)
+	if err != nil || res.RequeueAfter <= 0 || res.RequeueAfter > time.Hour+time.Second {
+		t.Fatalf("Reconcile() got %+v, %v, want requeue at the end of the window", res, err)
+	}
+	if !bc.intended[0].deferChanges {
+		t.Errorf("ReconcileBackends() got an intended status without deferred changes")
+	}
+	svc := &corev1.Service{}
+	if err := r.Get(ctx, key, svc); err != nil {
+		t.Fatalf("Get() got err: %v", err)
+	}
+	if !meta.IsStatusConditionTrue(svc.Status.Conditions, conditionChangesDeferred) {
+		t.Errorf("Service has no %s condition", conditionChangesDeferred)
+	}
+	if _, ok := svc.Annotations[autonegStatusAnnotation]; ok {
+		t.Errorf("Reconcile() stored the intended status of deferred changes")
+	}
+
+	// After the window the changes are applied and the condition removed.
+	cm.Data = nil
+	if err := r.Update(ctx, cm); err != nil {
+		t.Fatalf("Update() got err: %v", err)
+	}
+	bc.err = nil
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+	if err := r.Get(ctx, key, svc); err != nil {
+		t.Fatalf("Get() got err: %v", err)
+	}
+	if meta.FindStatusCondition(svc.Status.Conditions, conditionChangesDeferred) != nil {
+		t.Errorf("Service still has the %s condition", conditionChangesDeferred)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+const (
+	// computeHealthWindow is the time compute API calls may fail without
+	// any success before the compute API is reported unhealthy
+	computeHealthWindow = 2 * time.Minute
+	// operationBacklogLimit is the time operation polls may wait for the
+	// rate limiter before the poller is reported backlogged
+	operationBacklogLimit = 30 * time.Second
+	// cacheSyncTimeout is the time the readiness check waits for the
+	// informer caches to sync
+	cacheSyncTimeout = time.Second
+	// computeProbeInterval is the time between calls of the readiness
+	// check to the compute API until any call succeeded
+	computeProbeInterval = 30 * time.Second
+)
+
+// computeHealth records the outcome of the latest compute API calls
+type computeHealth struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastErr     error
+	lastProbe   time.Time
+}
+
+// observeCall records the outcome of a compute API call. Errors returned by
+// the API, e.g. backend services which do not exist, show that it is
+// reachable; only failures to reach it, authentication failures and server
+// errors count against its health.
+func (b *ProdBackendController) observeCall(err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	b.health.mu.Lock()
+	defer b.health.mu.Unlock()
+	var e *googleapi.Error
+	if err != nil && (!errors.As(err, &e) || e.Code == http.StatusUnauthorized || e.Code >= 500) {
+		b.health.lastFailure = time.Now()
+		b.health.lastErr = err
+		return
+	}
+	b.health.lastSuccess = time.Now()
+}
+
+// probeComputeAPI makes a cheap compute API call to observe its health
+func (b *ProdBackendController) probeComputeAPI(ctx context.Context) {
+	if err := b.wait(ctx, computeCallRead); err != nil {
+		return
+	}
+	_, err := compute.NewBackendServicesService(b.s).List(b.project).MaxResults(1).Fields("items/name").Context(ctx).Do()
+	b.observeCall(err)
+}
+
+// ComputeAPICheck is a readiness check failing until a compute API call
+// succeeded, and when the latest call failed and none succeeded recently.
+// Until a call succeeded, the check calls the compute API itself every
+// computeProbeInterval.
+func (b *ProdBackendController) ComputeAPICheck(req *http.Request) error {
+	b.health.mu.Lock()
+	probe := b.health.lastSuccess.IsZero() && time.Since(b.health.lastProbe) >= computeProbeInterval
+	if probe {
+		b.health.lastProbe = time.Now()
+	}
+	b.health.mu.Unlock()
+	if probe {
+		ctx := context.Background()
+		if req != nil {
+			ctx = req.Context()
+		}
+		b.probeComputeAPI(ctx)
+	}
+
+	b.health.mu.Lock()
+	defer b.health.mu.Unlock()
+	if b.health.lastSuccess.IsZero() {
+		if b.health.lastErr == nil {
+			return errors.New("no compute API call succeeded yet")
+		}
+		return fmt.Errorf("no compute API call succeeded: %w", b.health.lastErr)
+	}
+	if b.health.lastSuccess.After(b.health.lastFailure) {
+		return nil
+	}
+	if time.Since(b.health.lastSuccess) < computeHealthWindow {
+		return nil
+	}
+	return fmt.Errorf("no compute API call succeeded since %s: %w", b.health.lastSuccess.Format(time.RFC3339), b.health.lastErr)
+}
+
+// OperationPollerCheck is a readiness check failing when polls of compute
+// operations wait too long for the rate limiter
+func (b *ProdBackendController) OperationPollerCheck(_ *http.Request) error {
+	if b.operationLimiter == nil {
+		return nil
+	}
+	tokens := b.operationLimiter.Tokens()
+	if tokens >= 0 {
+		return nil
+	}
+	backlog := time.Duration(-tokens / float64(b.operationLimiter.Limit()) * float64(time.Second))
+	if backlog > operationBacklogLimit {
+		return fmt.Errorf("compute operation polls are backlogged by %s", backlog.Round(time.Second))
+	}
+	return nil
+}
+
+// CacheSyncCheck returns a readiness check failing until the informer
+// caches have synced
+func CacheSyncCheck(c cache.Cache) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), cacheSyncTimeout)
+		defer cancel()
+		if !c.WaitForCacheSync(ctx) {
+			return errors.New("informer caches have not synced")
+		}
+		return nil
+	}
+}
+
+// LeaderCheck is a readiness check failing while no replica leads. A
+// replica is ready when it was elected, or while another replica holds and
+// renews the leader election lease.
+type LeaderCheck struct {
+	Elected   <-chan struct{}
+	Reader    client.Reader
+	Namespace string
+	Name      string
+}
+
+// Check reports whether this or another replica leads
+func (c *LeaderCheck) Check(req *http.Request) error {
+	select {
+	case <-c.Elected:
+		return nil
+	default:
+	}
+	lease := &coordinationv1.Lease{}
+	if err := c.Reader.Get(req.Context(), types.NamespacedName{Namespace: c.Namespace, Name: c.Name}, lease); err != nil {
+		return fmt.Errorf("failed to get leader election lease: %w", err)
+	}
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || *spec.HolderIdentity == "" || spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return errors.New("no replica holds the leader election lease")
+	}
+	expiry := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+	if time.Now().After(expiry) {
+		return fmt.Errorf("the leader election lease of %s expired at %s", *spec.HolderIdentity, expiry.Format(time.RFC3339))
+	}
+	return nil
+}
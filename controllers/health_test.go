@@ -0,0 +1,188 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestComputeAPICheck(t *testing.T) {
+	probes := 0
+	s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		probes++
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(compute.BackendServiceList{})
+	}))
+	defer s.Close()
+	cs, err := compute.NewService(context.Background(), option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to instantiate compute service: %v", err)
+	}
+
+	// Without any calls yet, the check calls the compute API.
+	b := &ProdBackendController{project: fakeProject, s: cs}
+	if err := b.ComputeAPICheck(nil); err != nil || probes != 1 {
+		t.Errorf("ComputeAPICheck() without calls got err %v and %d probes, want none and 1", err, probes)
+	}
+
+	// Errors of single resources show that the API is reachable.
+	b.observeCall(&googleapi.Error{Code: http.StatusNotFound})
+	if err := b.ComputeAPICheck(nil); err != nil {
+		t.Errorf("ComputeAPICheck() after not found got err: %v", err)
+	}
+
+	// A recent success outweighs a failure.
+	b.observeCall(errors.New("dial tcp: connection refused"))
+	if err := b.ComputeAPICheck(nil); err != nil {
+		t.Errorf("ComputeAPICheck() after a recent success got err: %v", err)
+	}
+	b.health.lastSuccess = b.health.lastSuccess.Add(-computeHealthWindow)
+	if err := b.ComputeAPICheck(nil); err == nil {
+		t.Errorf("ComputeAPICheck() got no error after failures without recent success")
+	}
+
+	b.observeCall(nil)
+	if err := b.ComputeAPICheck(nil); err != nil {
+		t.Errorf("ComputeAPICheck() after a success got err: %v", err)
+	}
+
+	if probes != 1 {
+		t.Errorf("ComputeAPICheck() probed %d times after a success, want 1", probes)
+	}
+
+	// The check is not ready until a call succeeded.
+	b = &ProdBackendController{project: fakeProject, s: cs}
+	b.health.lastProbe = time.Now()
+	if err := b.ComputeAPICheck(nil); err == nil {
+		t.Errorf("ComputeAPICheck() got no error before any call succeeded")
+	}
+	b.observeCall(&googleapi.Error{Code: http.StatusUnauthorized})
+	if err := b.ComputeAPICheck(nil); err == nil {
+		t.Errorf("ComputeAPICheck() got no error after an authentication failure")
+	}
+	s.Close()
+	b.health.lastProbe = time.Time{}
+	if err := b.ComputeAPICheck(nil); err == nil {
+		t.Errorf("ComputeAPICheck() got no error with an unreachable compute API")
+	}
+}
+
+func TestOperationPollerCheck(t *testing.T) {
+	b := &ProdBackendController{}
+	if err := b.OperationPollerCheck(nil); err != nil {
+		t.Errorf("OperationPollerCheck() without rate limit got err: %v", err)
+	}
+
+	b.operationLimiter = rate.NewLimiter(1, 1)
+	now := time.Now()
+	for range 10 {
+		b.operationLimiter.ReserveN(now, 1)
+	}
+	if err := b.OperationPollerCheck(nil); err != nil {
+		t.Errorf("OperationPollerCheck() with a short backlog got err: %v", err)
+	}
+	for range 30 {
+		b.operationLimiter.ReserveN(now, 1)
+	}
+	if err := b.OperationPollerCheck(nil); err == nil {
+		t.Errorf("OperationPollerCheck() got no error with a long backlog")
+	}
+}
+
+func TestCacheSyncCheck(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	if err := CacheSyncCheck(&informertest.FakeInformers{Synced: ptr.To(false)})(req); err == nil {
+		t.Errorf("CacheSyncCheck() got no error before the caches synced")
+	}
+	if err := CacheSyncCheck(&informertest.FakeInformers{})(req); err != nil {
+		t.Errorf("CacheSyncCheck() got err: %v", err)
+	}
+}
+
+func TestLeaderCheck(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	lease := func(renewed time.Time) *coordinationv1.Lease {
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "autoneg-system", Name: "leader"},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To("other"),
+				LeaseDurationSeconds: ptr.To[int32](30),
+				RenewTime:            &metav1.MicroTime{Time: renewed},
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		elected bool
+		lease   *coordinationv1.Lease
+		ready   bool
+	}{
+		{
+			name:    "elected",
+			elected: true,
+			ready:   true,
+		},
+		{
+			name:  "other leader",
+			lease: lease(time.Now()),
+			ready: true,
+		},
+		{
+			name:  "lease expired",
+			lease: lease(time.Now().Add(-time.Minute)),
+		},
+		{
+			name: "no lease",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elected := make(chan struct{})
+			if tt.elected {
+				close(elected)
+			}
+			builder := fake.NewClientBuilder()
+			if tt.lease != nil {
+				builder = builder.WithObjects(tt.lease)
+			}
+			check := &LeaderCheck{Elected: elected, Reader: builder.Build(), Namespace: "autoneg-system", Name: "leader"}
+			err := check.Check(req)
+			if tt.ready && err != nil {
+				t.Errorf("Check() got err: %v", err)
+			}
+			if !tt.ready && err == nil {
+				t.Errorf("Check() got no error, want not ready")
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/compute/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// projectPermissionRetry is the time a failed project permission check is
+// reported before testing the permissions again
+const projectPermissionRetry = time.Minute
+
+var (
+	// projectPermissions are tested on the project before the controller
+	// becomes ready
+	projectPermissions = []string{
+		"compute.backendServices.get",
+		"compute.backendServices.update",
+		"compute.networkEndpointGroups.use",
+	}
+	// backendServicePermissions are tested on each global backend service
+	// before its first use
+	backendServicePermissions = []string{
+		"compute.backendServices.get",
+		"compute.backendServices.update",
+	}
+	// regionBackendServicePermissions are tested on each regional backend
+	// service before its first use
+	regionBackendServicePermissions = []string{
+		"compute.regionBackendServices.get",
+		"compute.regionBackendServices.update",
+	}
+)
+
+// errPermissionDenied is returned when the controller lacks permissions on
+// a resource
+type errPermissionDenied struct {
+	Resource string
+	Missing  []string
+}
+
+func (e *errPermissionDenied) Error() string {
+	return fmt.Sprintf("missing permissions on %s: %s", e.Resource, strings.Join(e.Missing, ", "))
+}
+
+// missingPermissions returns the wanted permissions which were not granted
+func missingPermissions(wanted, granted []string) []string {
+	var missing []string
+	for _, p := range wanted {
+		if !slices.Contains(granted, p) {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// permittedBackendServices holds the backend services whose permissions
+// were tested successfully
+type permittedBackendServices struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+func newPermittedBackendServices(enabled bool) *permittedBackendServices {
+	if !enabled {
+		return nil
+	}
+	return &permittedBackendServices{keys: map[string]struct{}{}}
+}
+
+// checkPermissions tests the permissions of the controller on a backend
+// service before its first use. Failures to test the permissions are
+// ignored, the backend service calls report them.
+func (b *ProdBackendController) checkPermissions(ctx context.Context, name string, region string) error {
+	if b.permitted == nil {
+		return nil
+	}
+	key := backendServiceCacheKey(b.project, region, name)
+	b.permitted.mu.Lock()
+	_, ok := b.permitted.keys[key]
+	b.permitted.mu.Unlock()
+	if ok {
+		return nil
+	}
+
+	logger := log.FromContext(ctx)
+	if err := b.wait(ctx, computeCallRead); err != nil {
+		return err
+	}
+	wanted := backendServicePermissions
+	var res *compute.TestPermissionsResponse
+	var err error
+	if region == "" {
+		res, err = compute.NewBackendServicesService(b.s).TestIamPermissions(b.project, name, &compute.TestPermissionsRequest{Permissions: wanted}).Do()
+	} else {
+		wanted = regionBackendServicePermissions
+		res, err = compute.NewRegionBackendServicesService(b.s).TestIamPermissions(b.project, region, name, &compute.TestPermissionsRequest{Permissions: wanted}).Do()
+	}
+	b.observeCall(err)
+	if err != nil {
+		logger.V(1).Info("Failed to test permissions on backend service", "project", b.project, "region", region, "name", name, "error", err.Error())
+		return nil
+	}
+	if missing := missingPermissions(wanted, res.Permissions); len(missing) > 0 {
+		resource := fmt.Sprintf("backend service %s", name)
+		if region != "" {
+			resource = fmt.Sprintf("backend service %s in region %s", name, region)
+		}
+		return &errPermissionDenied{Resource: resource, Missing: missing}
+	}
+	b.permitted.mu.Lock()
+	b.permitted.keys[key] = struct{}{}
+	b.permitted.mu.Unlock()
+	return nil
+}
+
+// forgetPermissions tests the permissions on a backend service again before
+// its next use when a call on it was denied, e.g. after a role was revoked
+func (b *ProdBackendController) forgetPermissions(name string, region string, err error) {
+	if b.permitted == nil || err == nil {
+		return
+	}
+	if class, _ := classifyError(err); class != errorClassPermissionDenied {
+		return
+	}
+	b.permitted.mu.Lock()
+	delete(b.permitted.keys, backendServiceCacheKey(b.project, region, name))
+	b.permitted.mu.Unlock()
+}
+
+// ProjectPermissionCheck is a readiness check testing the permissions of
+// the controller on the project. Once they were granted, they are not
+// tested again.
+type ProjectPermissionCheck struct {
+	Project string
+	Service *cloudresourcemanager.Service
+
+	mu      sync.Mutex
+	ready   bool
+	err     error
+	checked time.Time
+}
+
+// Check tests the permissions on the project, a failure is reported for a
+// minute before testing again
+func (c *ProjectPermissionCheck) Check(_ *http.Request) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ready || (c.err != nil && time.Since(c.checked) < projectPermissionRetry) {
+		return c.err
+	}
+	c.checked = time.Now()
+	res, err := c.Service.Projects.TestIamPermissions(c.Project, &cloudresourcemanager.TestIamPermissionsRequest{Permissions: projectPermissions}).Do()
+	if err != nil {
+		c.err = fmt.Errorf("failed to test permissions on project %s: %w", c.Project, err)
+		return c.err
+	}
+	if missing := missingPermissions(projectPermissions, res.Permissions); len(missing) > 0 {
+		c.err = &errPermissionDenied{Resource: fmt.Sprintf("project %s", c.Project), Missing: missing}
+		return c.err
+	}
+	c.ready, c.err = true, nil
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// permissionServer answers testIamPermissions requests with the requested
+// permissions which are granted, and counts them
+func permissionServer(t *testing.T, granted *[]string, tests *int) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(req.URL.Path, "testIamPermissions") {
+			*tests++
+			var body struct{ Permissions []string }
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				t.Errorf("Failed to decode request: %v", err)
+			}
+			var permissions []string
+			for _, p := range body.Permissions {
+				if slices.Contains(*granted, p) {
+					permissions = append(permissions, p)
+				}
+			}
+			json.NewEncoder(res).Encode(map[string][]string{"permissions": permissions})
+			return
+		}
+		json.NewEncoder(res).Encode(compute.BackendService{Name: "web"})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestCheckPermissions(t *testing.T) {
+	ctx := context.Background()
+	granted := []string{"compute.backendServices.get"}
+	tests := 0
+	s := permissionServer(t, &granted, &tests)
+	cs, err := compute.NewService(ctx, option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to instantiate compute service: %v", err)
+	}
+	bc := NewBackendController(fakeProject, cs, BackendControllerOptions{CheckPermissions: true})
+
+	_, err = bc.getBackendService(ctx, "web", "")
+	var denied *errPermissionDenied
+	if !errors.As(err, &denied) {
+		t.Fatalf("getBackendService() got err %v, want permission denied", err)
+	}
+	if want := []string{"compute.backendServices.update"}; !reflect.DeepEqual(denied.Missing, want) {
+		t.Errorf("getBackendService() got missing permissions %v, want %v", denied.Missing, want)
+	}
+	if class, permanent := classifyError(err); class != errorClassPermissionDenied || permanent {
+		t.Errorf("classifyError() got %q, %v, want %q, false", class, permanent, errorClassPermissionDenied)
+	}
+
+	// Once granted, the permissions are not tested again.
+	granted = append(granted, "compute.backendServices.update")
+	for range 2 {
+		if _, err = bc.getBackendService(ctx, "web", ""); err != nil {
+			t.Fatalf("getBackendService() got err: %v", err)
+		}
+	}
+	if tests != 2 {
+		t.Errorf("testIamPermissions called %d times, want 2", tests)
+	}
+
+	// A later denied call tests the permissions again.
+	bc.forgetPermissions("web", "", &googleapi.Error{Code: http.StatusBadRequest})
+	if _, err = bc.getBackendService(ctx, "web", ""); err != nil || tests != 2 {
+		t.Errorf("getBackendService() got err %v and %d permission tests, want none and 2", err, tests)
+	}
+	bc.forgetPermissions("web", "", &googleapi.Error{Code: http.StatusForbidden})
+	if _, err = bc.getBackendService(ctx, "web", ""); err != nil || tests != 3 {
+		t.Errorf("getBackendService() got err %v and %d permission tests, want none and 3", err, tests)
+	}
+
+	_, err = bc.getBackendService(ctx, "web", "europe-west4")
+	if !errors.As(err, &denied) || len(denied.Missing) != 2 || !strings.Contains(err.Error(), "in region europe-west4") {
+		t.Errorf("getBackendService() got err %v, want regional permissions missing", err)
+	}
+
+	// Without the option, no permissions are tested.
+	bc = NewBackendController(fakeProject, cs, BackendControllerOptions{})
+	if _, err = bc.getBackendService(ctx, "other", "europe-west4"); err != nil {
+		t.Errorf("getBackendService() got err: %v", err)
+	}
+	if tests != 4 {
+		t.Errorf("testIamPermissions called %d times, want 4", tests)
+	}
+}
+
+func TestProjectPermissionCheck(t *testing.T) {
+	ctx := context.Background()
+	granted := []string{"compute.backendServices.get", "compute.backendServices.update"}
+	tests := 0
+	s := permissionServer(t, &granted, &tests)
+	crm, err := cloudresourcemanager.NewService(ctx, option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to instantiate cloud resource manager service: %v", err)
+	}
+	check := &ProjectPermissionCheck{Project: fakeProject, Service: crm}
+
+	err = check.Check(nil)
+	if err == nil || err.Error() != "missing permissions on project project: compute.networkEndpointGroups.use" {
+		t.Fatalf("Check() got err %v, want compute.networkEndpointGroups.use missing", err)
+	}
+	// A failure is reported without testing again until it is retried.
+	granted = append(granted, "compute.networkEndpointGroups.use")
+	if err = check.Check(nil); err == nil {
+		t.Errorf("Check() got no error before the retry")
+	}
+	check.checked = check.checked.Add(-projectPermissionRetry)
+	if err = check.Check(nil); err != nil {
+		t.Errorf("Check() got err: %v", err)
+	}
+	if err = check.Check(nil); err != nil {
+		t.Errorf("Check() got err: %v", err)
+	}
+	if tests != 2 {
+		t.Errorf("testIamPermissions called %d times, want 2", tests)
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/GoogleCloudPlatform/gke-autoneg-controller/api/v1alpha1"
+)
+
+// policyRegionGlobal is the region of global backend services in policies
+const policyRegionGlobal = "global"
+
+// errBackendServiceNotAllowed is returned when a service targets a backend
+// service its namespace may not use
+type errBackendServiceNotAllowed struct {
+	Namespace      string
+	BackendService string
+	Region         string
+	Project        string
+}
+
+func (e *errBackendServiceNotAllowed) Error() string {
+	return fmt.Sprintf("%s: namespace %q may not use backend service %q in region %q of project %q, no AutonegPolicy rule allows it",
+		errConfigInvalid, e.Namespace, e.BackendService, e.Region, e.Project)
+}
+
+func (e *errBackendServiceNotAllowed) Unwrap() error {
+	return errConfigInvalid
+}
+
+// policyRegion returns the region of a backend service as named in policies
+func policyRegion(region string) string {
+	if region == "" {
+		return policyRegionGlobal
+	}
+	return region
+}
+
+// appliesTo returns true if the rule selects the namespace. The labels of
+// the namespace are only read for rules with a selector.
+func appliesTo(ctx context.Context, reader client.Reader, rule v1alpha1.AutonegPolicyRule, namespace string, nsLabels *labels.Set) (bool, error) {
+	if len(rule.Namespaces) == 0 && rule.NamespaceSelector == nil {
+		return true, nil
+	}
+	if slices.Contains(rule.Namespaces, namespace) {
+		return true, nil
+	}
+	if rule.NamespaceSelector == nil {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(rule.NamespaceSelector)
+	if err != nil {
+		return false, fmt.Errorf("%w: invalid namespace selector: %v", errConfigInvalid, err)
+	}
+	if *nsLabels == nil {
+		ns := &corev1.Namespace{}
+		if err = reader.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+			return false, err
+		}
+		*nsLabels = labels.Set(ns.Labels)
+		if *nsLabels == nil {
+			*nsLabels = labels.Set{}
+		}
+	}
+	return selector.Matches(*nsLabels), nil
+}
+
+// allows returns true if the rule allows the backend service
+func allows(rule v1alpha1.AutonegPolicyRule, name string, region string, project string) (bool, error) {
+	if len(rule.Regions) > 0 && !slices.Contains(rule.Regions, policyRegion(region)) {
+		return false, nil
+	}
+	if len(rule.Projects) > 0 && !slices.Contains(rule.Projects, project) {
+		return false, nil
+	}
+	for _, pattern := range rule.BackendServices {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return false, fmt.Errorf("%w: backend service pattern %q is not a regular expression: %v", errConfigInvalid, pattern, err)
+		}
+		if re.MatchString(name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// authorizeBackendServices checks that the AutonegPolicies allow the
+// namespace to use all backend services of the config. Without any policy,
+// all backend services are allowed unless a policy is required.
+func (r *ServiceReconciler) authorizeBackendServices(ctx context.Context, namespace string, config AutonegConfig) error {
+	policies := &v1alpha1.AutonegPolicyList{}
+	if err := r.List(ctx, policies); err != nil {
+		return fmt.Errorf("failed to list autoneg policies: %w", err)
+	}
+	if len(policies.Items) == 0 && !r.RequireNamespacePolicy {
+		return nil
+	}
+	var nsLabels labels.Set
+	for _, port := range slices.Sorted(maps.Keys(config.BackendServices)) {
+		for _, name := range slices.Sorted(maps.Keys(config.BackendServices[port])) {
+			cfg := config.BackendServices[port][name]
+			allowed := false
+			for _, policy := range policies.Items {
+				for _, rule := range policy.Spec.Rules {
+					ok, err := appliesTo(ctx, r, rule, namespace, &nsLabels)
+					if err == nil && ok {
+						ok, err = allows(rule, cfg.Name, cfg.Region, r.Project)
+					}
+					if err != nil {
+						return fmt.Errorf("AutonegPolicy %s: %w", policy.Name, err)
+					}
+					allowed = allowed || ok
+				}
+			}
+			if !allowed {
+				return &errBackendServiceNotAllowed{Namespace: namespace, BackendService: cfg.Name, Region: policyRegion(cfg.Region), Project: r.Project}
+			}
+		}
+	}
+	return nil
+}
+
+// SetupWebhookWithManager registers the admission webhook enforcing the
+// AutonegPolicies
+func (r *ServiceReconciler) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&corev1.Service{}).
+		WithValidator(&PolicyValidator{Reconciler: r}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate--v1-service,mutating=false,failurePolicy=ignore,sideEffects=None,groups="",resources=services,verbs=create;update,versions=v1,name=vservice.controller.autoneg.dev,admissionReviewVersions=v1
+
+// PolicyValidator is an admission webhook rejecting services which target
+// backend services their namespace may not use
+type PolicyValidator struct {
+	Reconciler *ServiceReconciler
+}
+
+var _ admission.CustomValidator = &PolicyValidator{}
+
+// ValidateCreate checks the backend services of a new service
+func (v *PolicyValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	svc, ok := obj.(*corev1.Service)
+	if !ok {
+		return nil, fmt.Errorf("expected a Service but got %T", obj)
+	}
+	return nil, v.validate(ctx, svc)
+}
+
+// ValidateUpdate checks the backend services of a service whose autoneg
+// annotation changed. Other updates, e.g. by autoneg itself, are allowed, so
+// a policy change never blocks a service from being deleted.
+func (v *PolicyValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldSvc, ok := oldObj.(*corev1.Service)
+	if !ok {
+		return nil, fmt.Errorf("expected a Service but got %T", oldObj)
+	}
+	svc, ok := newObj.(*corev1.Service)
+	if !ok {
+		return nil, fmt.Errorf("expected a Service but got %T", newObj)
+	}
+	if !svc.DeletionTimestamp.IsZero() || oldSvc.Annotations[autonegAnnotation] == svc.Annotations[autonegAnnotation] {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, svc)
+}
+
+// ValidateDelete allows deleting any service
+func (v *PolicyValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *PolicyValidator) validate(ctx context.Context, svc *corev1.Service) error {
+	tmp, ok := svc.Annotations[autonegAnnotation]
+	if !ok {
+		return nil
+	}
+	var tempConfig AutonegConfigTemp
+	if err := json.Unmarshal([]byte(tmp), &tempConfig); err != nil {
+		// Malformed annotations are reported by the controller.
+		return nil
+	}
+	config := v.Reconciler.autonegConfig(svc.Namespace, svc.Name, tempConfig)
+	return v.Reconciler.authorizeBackendServices(ctx, svc.Namespace, config)
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/GoogleCloudPlatform/gke-autoneg-controller/api/v1alpha1"
+)
+
+// newPolicyTestReconciler returns a reconciler enforcing the policies, with
+// the namespaces team-a labeled tier=prod and team-b
+func newPolicyTestReconciler(t *testing.T, objs ...client.Object) *ServiceReconciler {
+	testScheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(testScheme); err != nil {
+		t.Fatalf("AddToScheme() got err: %v", err)
+	}
+	if err := v1alpha1.AddToScheme(testScheme); err != nil {
+		t.Fatalf("AddToScheme() got err: %v", err)
+	}
+	r := newTestReconciler()
+	r.Client = fake.NewClientBuilder().
+		WithScheme(testScheme).
+		WithStatusSubresource(&corev1.Service{}).
+		WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"tier": "prod"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+		).
+		WithObjects(objs...).
+		Build()
+	r.NamespacePolicy = true
+	r.Project = fakeProject
+	return r
+}
+
+func TestAuthorizeBackendServices(t *testing.T) {
+	policy := func(rules ...v1alpha1.AutonegPolicyRule) *v1alpha1.AutonegPolicy {
+		return &v1alpha1.AutonegPolicy{ObjectMeta: metav1.ObjectMeta{Name: "policy"}, Spec: v1alpha1.AutonegPolicySpec{Rules: rules}}
+	}
+	config := func(name, region string) AutonegConfig {
+		return AutonegConfig{BackendServices: map[string]map[string]AutonegNEGConfig{
+			"80": {name: {Name: name, Region: region}},
+		}}
+	}
+	tests := []struct {
+		name      string
+		policy    *v1alpha1.AutonegPolicy
+		namespace string
+		config    AutonegConfig
+		require   bool
+		allowed   bool
+	}{
+		{
+			name:      "no policy",
+			namespace: "team-b",
+			config:    config("prod-edge", ""),
+			allowed:   true,
+		},
+		{
+			name:      "no policy required",
+			namespace: "team-b",
+			config:    config("prod-edge", ""),
+			require:   true,
+		},
+		{
+			name:      "namespace allowed",
+			policy:    policy(v1alpha1.AutonegPolicyRule{Namespaces: []string{"team-a"}, BackendServices: []string{"team-a-.*"}}),
+			namespace: "team-a",
+			config:    config("team-a-web", ""),
+			allowed:   true,
+		},
+		{
+			name:      "whole name",
+			policy:    policy(v1alpha1.AutonegPolicyRule{Namespaces: []string{"team-a"}, BackendServices: []string{"team-a"}}),
+			namespace: "team-a",
+			config:    config("team-a-web", ""),
+		},
+		{
+			name:      "other namespace",
+			policy:    policy(v1alpha1.AutonegPolicyRule{Namespaces: []string{"team-a"}, BackendServices: []string{"team-a-.*"}}),
+			namespace: "team-b",
+			config:    config("team-a-web", ""),
+		},
+		{
+			name:      "namespace selector",
+			policy:    policy(v1alpha1.AutonegPolicyRule{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}}, BackendServices: []string{"prod-.*"}}),
+			namespace: "team-a",
+			config:    config("prod-edge", ""),
+			allowed:   true,
+		},
+		{
+			name:      "namespace selector not matching",
+			policy:    policy(v1alpha1.AutonegPolicyRule{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}}, BackendServices: []string{"prod-.*"}}),
+			namespace: "team-b",
+			config:    config("prod-edge", ""),
+		},
+		{
+			name:      "all namespaces",
+			policy:    policy(v1alpha1.AutonegPolicyRule{BackendServices: []string{"shared-.*"}}),
+			namespace: "team-b",
+			config:    config("shared-web", ""),
+			allowed:   true,
+		},
+		{
+			name:      "global region",
+			policy:    policy(v1alpha1.AutonegPolicyRule{BackendServices: []string{".*"}, Regions: []string{"global"}}),
+			namespace: "team-b",
+			config:    config("web", ""),
+			allowed:   true,
+		},
+		{
+			name:      "region not allowed",
+			policy:    policy(v1alpha1.AutonegPolicyRule{BackendServices: []string{".*"}, Regions: []string{"global"}}),
+			namespace: "team-b",
+			config:    config("web", "europe-west4"),
+		},
+		{
+			name:      "project not allowed",
+			policy:    policy(v1alpha1.AutonegPolicyRule{BackendServices: []string{".*"}, Projects: []string{"other-project"}}),
+			namespace: "team-b",
+			config:    config("web", ""),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objs []client.Object
+			if tt.policy != nil {
+				objs = append(objs, tt.policy)
+			}
+			r := newPolicyTestReconciler(t, objs...)
+			r.RequireNamespacePolicy = tt.require
+			err := r.authorizeBackendServices(context.Background(), tt.namespace, tt.config)
+			if tt.allowed {
+				if err != nil {
+					t.Errorf("authorizeBackendServices() got err: %v", err)
+				}
+				return
+			}
+			var notAllowed *errBackendServiceNotAllowed
+			if !errors.As(err, &notAllowed) || !errors.Is(err, errConfigInvalid) {
+				t.Errorf("authorizeBackendServices() got err %v, want backend service not allowed", err)
+			}
+		})
+	}
+}
+
+func TestReconcileNamespacePolicy(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "team-b", Name: "svc"}
+	r := newPolicyTestReconciler(t,
+		&v1alpha1.AutonegPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod"},
+			Spec: v1alpha1.AutonegPolicySpec{Rules: []v1alpha1.AutonegPolicyRule{
+				{Namespaces: []string{"team-a"}, BackendServices: []string{"prod-edge"}},
+			}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   key.Namespace,
+				Name:        key.Name,
+				Finalizers:  []string{autonegFinalizer},
+				Annotations: map[string]string{autonegAnnotation: `{"backend_services":{"80":[{"name":"prod-edge","max_rate_per_endpoint":100}]}}`},
+			},
+		})
+	bc := &TestBackendController{}
+	r.BackendController = bc
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+	req := ctrl.Request{NamespacedName: key}
+
+	if _, err := r.Reconcile(ctx, req); !errors.Is(err, errConfigInvalid) {
+		t.Fatalf("Reconcile() got err %v, want configuration invalid", err)
+	}
+	if bc.Counter != 0 {
+		t.Errorf("ReconcileBackends() called %d times, want 0", bc.Counter)
+	}
+	if event := <-recorder.Events; !strings.Contains(event, "ConfigError") || !strings.Contains(event, `may not use backend service "prod-edge"`) {
+		t.Errorf("Reconcile() recorded event %q, want a ConfigError for prod-edge", event)
+	}
+
+	// The NEGs of a deleted service are deregistered anyway.
+	svc := &corev1.Service{}
+	if err := r.Get(ctx, key, svc); err != nil {
+		t.Fatalf("Get() got err: %v", err)
+	}
+	if err := r.Delete(ctx, svc); err != nil {
+		t.Fatalf("Delete() got err: %v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+	if bc.Counter != 1 {
+		t.Errorf("ReconcileBackends() called %d times, want 1", bc.Counter)
+	}
+}
+
+func TestPolicyValidator(t *testing.T) {
+	ctx := context.Background()
+	r := newPolicyTestReconciler(t, &v1alpha1.AutonegPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod"},
+		Spec: v1alpha1.AutonegPolicySpec{Rules: []v1alpha1.AutonegPolicyRule{
+			{Namespaces: []string{"team-a"}, BackendServices: []string{"prod-edge"}},
+		}},
+	})
+	v := &PolicyValidator{Reconciler: r}
+	service := func(namespace, backendService string) *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        "svc",
+			Annotations: map[string]string{autonegAnnotation: `{"backend_services":{"80":[{"name":"` + backendService + `"}]}}`},
+		}}
+	}
+
+	if _, err := v.ValidateCreate(ctx, service("team-a", "prod-edge")); err != nil {
+		t.Errorf("ValidateCreate() got err: %v", err)
+	}
+	if _, err := v.ValidateCreate(ctx, service("team-b", "prod-edge")); err == nil {
+		t.Errorf("ValidateCreate() got no error for a backend service which is not allowed")
+	}
+	if _, err := v.ValidateCreate(ctx, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "svc"}}); err != nil {
+		t.Errorf("ValidateCreate() got err for a service without autoneg: %v", err)
+	}
+	// Updates keeping the autoneg annotation, e.g. by autoneg, are allowed.
+	if _, err := v.ValidateUpdate(ctx, service("team-b", "prod-edge"), service("team-b", "prod-edge")); err != nil {
+		t.Errorf("ValidateUpdate() got err: %v", err)
+	}
+	if _, err := v.ValidateUpdate(ctx, service("team-b", "web"), service("team-b", "prod-edge")); err == nil {
+		t.Errorf("ValidateUpdate() got no error for a backend service which is not allowed")
+	}
+}
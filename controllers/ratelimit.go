@@ -0,0 +1,113 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Kinds of compute API calls which are rate limited separately
+const (
+	computeCallRead      = "read"
+	computeCallWrite     = "write"
+	computeCallOperation = "operation"
+)
+
+// ComputeRateLimit configures a token bucket for a kind of compute API calls.
+// A zero QPS disables rate limiting.
+type ComputeRateLimit struct {
+	QPS   float64
+	Burst int
+}
+
+// ComputeRateLimits configures the client-side rate limits of the compute
+// API calls made by ProdBackendController
+type ComputeRateLimits struct {
+	Reads      ComputeRateLimit
+	Writes     ComputeRateLimit
+	Operations ComputeRateLimit
+}
+
+func (l ComputeRateLimit) limiter() *rate.Limiter {
+	if l.QPS <= 0 {
+		return nil
+	}
+	burst := l.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(l.QPS), burst)
+}
+
+// RegisterMetrics registers the metrics of the backend controller
+func (b *ProdBackendController) RegisterMetrics() {
+	b.MetricThrottledSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "compute_api_throttled_seconds",
+			Help:    "Time compute API calls waited for the client-side rate limiter",
+			Buckets: []float64{0.001, 0.01, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"kind"},
+	)
+	b.MetricCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "backend_service_cache_requests_total",
+			Help: "Number of backend service reads by cache result (hit, revalidated, miss)",
+		},
+		[]string{"result"},
+	)
+	b.MetricChangeBudgetQueued = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "change_budget_queued_services",
+			Help: "Number of services whose backend service changes wait for the change budget",
+		},
+	)
+	metrics.Registry.MustRegister(b.MetricThrottledSeconds, b.MetricCacheRequests, b.MetricChangeBudgetQueued)
+}
+
+// wait blocks until the rate limiter of the given kind of calls allows
+// another call, or the context is done.
+func (b *ProdBackendController) wait(ctx context.Context, kind string) error {
+	var limiter *rate.Limiter
+	switch kind {
+	case computeCallRead:
+		limiter = b.readLimiter
+	case computeCallWrite:
+		limiter = b.writeLimiter
+	case computeCallOperation:
+		limiter = b.operationLimiter
+	}
+	if limiter == nil {
+		return nil
+	}
+	start := time.Now()
+	err := limiter.Wait(ctx)
+	waited := time.Since(start)
+	if b.MetricThrottledSeconds != nil {
+		b.MetricThrottledSeconds.With(prometheus.Labels{"kind": kind}).Observe(waited.Seconds())
+	}
+	if waited > time.Second {
+		log.FromContext(ctx).V(1).Info("Compute API call throttled", "kind", kind, "waited", waited.String())
+	}
+	return err
+}
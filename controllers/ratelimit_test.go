@@ -0,0 +1,83 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/time/rate"
+)
+
+func TestComputeRateLimitLimiter(t *testing.T) {
+	if l := (ComputeRateLimit{QPS: 0, Burst: 10}).limiter(); l != nil {
+		t.Errorf("limiter() with QPS 0 = %v, want nil", l)
+	}
+	if l := (ComputeRateLimit{QPS: -1}).limiter(); l != nil {
+		t.Errorf("limiter() with negative QPS = %v, want nil", l)
+	}
+	l := (ComputeRateLimit{QPS: 5, Burst: 0}).limiter()
+	if l == nil || l.Limit() != 5 || l.Burst() != 1 {
+		t.Errorf("limiter() with burst 0 = %v, want 5 QPS with burst 1", l)
+	}
+	if l := (ComputeRateLimit{QPS: 5, Burst: 10}).limiter(); l.Burst() != 10 {
+		t.Errorf("limiter() burst = %d, want 10", l.Burst())
+	}
+}
+
+func TestWait(t *testing.T) {
+	ctx := context.Background()
+	b := NewBackendController(fakeProject, nil, BackendControllerOptions{RateLimits: ComputeRateLimits{
+		Reads:      ComputeRateLimit{QPS: 100, Burst: 1},
+		Writes:     ComputeRateLimit{QPS: 100, Burst: 1},
+		Operations: ComputeRateLimit{QPS: 100, Burst: 1},
+	}})
+	b.MetricThrottledSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "throttled_seconds"}, []string{"kind"})
+	for _, kind := range []string{computeCallRead, computeCallWrite, computeCallOperation} {
+		if err := b.wait(ctx, kind); err != nil {
+			t.Errorf("wait(%s) got err: %v", kind, err)
+		}
+	}
+	if got := testutil.CollectAndCount(b.MetricThrottledSeconds); got != 3 {
+		t.Errorf("compute_api_throttled_seconds has %d series, want one per kind", got)
+	}
+
+	// A cancelled context ends the wait for an exhausted limiter.
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	b.readLimiter = rate.NewLimiter(rate.Every(time.Hour), 1)
+	b.readLimiter.Allow()
+	if err := b.wait(cancelled, computeCallRead); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait() with a cancelled context got err %v, want %v", err, context.Canceled)
+	}
+
+	// Without limits, calls are neither limited nor observed.
+	b = NewBackendController(fakeProject, nil, BackendControllerOptions{})
+	b.MetricThrottledSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "throttled_seconds"}, []string{"kind"})
+	for range 100 {
+		if err := b.wait(cancelled, computeCallWrite); err != nil {
+			t.Fatalf("wait() without limit got err: %v", err)
+		}
+	}
+	if got := testutil.CollectAndCount(b.MetricThrottledSeconds); got != 0 {
+		t.Errorf("compute_api_throttled_seconds has %d series without limits, want 0", got)
+	}
+}
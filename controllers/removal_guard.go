@@ -0,0 +1,118 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// autonegMaxBackendRemovalAnnotation overrides the maximum percentage
+	// of backends a single reconciliation may remove
+	autonegMaxBackendRemovalAnnotation = "controller.autoneg.dev/max-backend-removal-percent"
+	// autonegAllowBackendRemovalAnnotation lets the next reconciliation
+	// remove backends beyond the maximum, it is removed afterwards
+	autonegAllowBackendRemovalAnnotation = "controller.autoneg.dev/allow-backend-removal"
+)
+
+// backendRemoval counts the backends a reconciliation removes from backend
+// services which stay configured, i.e. the removals caused by changed NEGs
+// or zones rather than by a changed autoneg annotation.
+type backendRemoval struct {
+	removed int
+	total   int
+	// emptied are the backend services left without backends of the
+	// service
+	emptied []string
+}
+
+// parseMaxBackendRemoval parses the maximum percentage of backends a single
+// reconciliation may remove, 0 disables the guard
+func parseMaxBackendRemoval(value string) (int, error) {
+	percent, err := strconv.Atoi(value)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("%w: %s must be an integer between 0 and 100, but was %q", errConfigInvalid, autonegMaxBackendRemovalAnnotation, value)
+	}
+	return percent, nil
+}
+
+// countBackendRemoval counts the backends of the actual status, and the ones
+// reconciling to the intended status removes.
+func countBackendRemoval(actual, intended AutonegStatus) backendRemoval {
+	// The project only changes the URLs of the groups, not their number.
+	removes, upserts := ReconcileStatus(logr.Discard(), "", actual, intended)
+
+	var removal backendRemoval
+	for port, cfgs := range actual.BackendServices {
+		groups := statusGroups("", actual, port)
+		for name := range cfgs {
+			for group := range groups {
+				if actual.attached(name, port, group) {
+					removal.total++
+				}
+			}
+		}
+	}
+	for port, portRemoves := range removes {
+		for name, remove := range portRemoves {
+			cfg, ok := intended.BackendServices[port][name]
+			if !ok || cfg.Name != remove.name || len(remove.backends) == 0 {
+				continue
+			}
+			removal.removed += len(remove.backends)
+			if len(upserts[port][name].backends) == 0 {
+				removal.emptied = append(removal.emptied, remove.name)
+			}
+		}
+	}
+	slices.Sort(removal.emptied)
+	return removal
+}
+
+// exceeds returns why the removal exceeds the maximum percentage of
+// backends, or an empty string if it does not.
+func (b backendRemoval) exceeds(maxPercent int) string {
+	if len(b.emptied) > 0 {
+		return fmt.Sprintf("would leave backend services %s without backends", strings.Join(b.emptied, ", "))
+	}
+	if b.removed*100 > maxPercent*b.total {
+		return fmt.Sprintf("would remove %d of %d backends, more than %d%%", b.removed, b.total, maxPercent)
+	}
+	return ""
+}
+
+// blockBackendRemoval records a reconciliation refused by the removal guard
+// as a warning event and the Synced condition. The service is reconciled
+// again when it changes, e.g. when the override annotation is set.
+func (r *ServiceReconciler) blockBackendRemoval(ctx context.Context, logger logr.Logger, svc *corev1.Service, errorKey string, reason string) (reconcile.Result, error) {
+	message := fmt.Sprintf("Refusing to reconcile backends, the reconciliation %s; set the %s annotation to proceed", reason, autonegAllowBackendRemovalAnnotation)
+	logger.Info("Backend removal blocked", "reason", reason)
+	r.Recorder.Event(svc, "Warning", "BackendRemovalBlocked", message)
+	if err := r.setCondition(ctx, svc, conditionSynced, metav1.ConditionFalse, "BackendRemovalBlocked", message); err != nil {
+		logger.Error(err, "Failed to update service status")
+	}
+	return r.reconcileResult(ctx, logger, svc, errorKey, nil)
+}
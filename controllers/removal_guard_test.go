@@ -0,0 +1,174 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestCountBackendRemoval(t *testing.T) {
+	oneZone := statusBasicWithNEGs
+	oneZone.NEGStatus = NEGStatus{NEGs: negStatus.NEGs, Zones: []string{"zone1"}}
+	noZones := statusBasicWithNEGs
+	noZones.NEGStatus = NEGStatus{NEGs: negStatus.NEGs, Zones: []string{}}
+	renamed := statusBasicWithNEGs
+	renamed.AutonegConfig = AutonegConfig{BackendServices: map[string]map[string]AutonegNEGConfig{
+		"80": {"test": {Name: "other", Rate: 100}},
+	}}
+
+	tests := []struct {
+		name     string
+		actual   AutonegStatus
+		intended AutonegStatus
+		want     backendRemoval
+		// exceeds is the largest maximum percentage the removal exceeds,
+		// or -1 if it exceeds none
+		exceeds int
+	}{
+		{
+			name:     "zone removed",
+			actual:   statusBasicWithNEGs,
+			intended: oneZone,
+			want:     backendRemoval{removed: 1, total: 2},
+			exceeds:  49,
+		},
+		{
+			name:     "all zones removed",
+			actual:   statusBasicWithNEGs,
+			intended: noZones,
+			want:     backendRemoval{removed: 2, total: 2, emptied: []string{"test"}},
+			exceeds:  100,
+		},
+		{
+			name:     "zone added",
+			actual:   oneZone,
+			intended: statusBasicWithNEGs,
+			want:     backendRemoval{total: 1},
+			exceeds:  -1,
+		},
+		{
+			name:     "backend service renamed",
+			actual:   statusBasicWithNEGs,
+			intended: renamed,
+			want:     backendRemoval{total: 2},
+			exceeds:  -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := countBackendRemoval(tt.actual, tt.intended)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("countBackendRemoval() = %+v, want %+v", got, tt.want)
+			}
+			if tt.exceeds >= 0 && got.exceeds(tt.exceeds) == "" {
+				t.Errorf("exceeds(%d) = \"\", want a reason", tt.exceeds)
+			}
+			if tt.exceeds < 100 && got.exceeds(tt.exceeds+1) != "" {
+				t.Errorf("exceeds(%d) = %q, want none", tt.exceeds+1, got.exceeds(tt.exceeds+1))
+			}
+		})
+	}
+
+	for _, value := range []string{"-1", "101", "half"} {
+		if _, err := parseMaxBackendRemoval(value); err == nil {
+			t.Errorf("parseMaxBackendRemoval(%q) got no error", value)
+		}
+	}
+}
+
+func TestReconcileBackendRemovalGuard(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	applied, err := json.Marshal(statusBasicWithNEGs)
+	if err != nil {
+		t.Fatalf("json.Marshal() got err: %v", err)
+	}
+	r := newTestReconciler(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  key.Namespace,
+			Name:       key.Name,
+			Finalizers: []string{autonegFinalizer},
+			Annotations: map[string]string{
+				autonegAnnotation:       `{"backend_services":{"80":[{"name":"test","max_rate_per_endpoint":100}]}}`,
+				negStatusAnnotation:     `{"network_endpoint_groups":{"80":"neg_name"},"zones":["zone1"]}`,
+				autonegStatusAnnotation: string(applied),
+			},
+		},
+	})
+	r.MaxBackendRemovalPercent = 25
+	bc := &TestBackendController{}
+	r.BackendController = bc
+	req := ctrl.Request{NamespacedName: key}
+
+	getService := func() (*corev1.Service, AutonegStatus) {
+		svc := &corev1.Service{}
+		if err := r.Get(ctx, key, svc); err != nil {
+			t.Fatalf("Get() got err: %v", err)
+		}
+		var status AutonegStatus
+		if err := json.Unmarshal([]byte(svc.Annotations[autonegStatusAnnotation]), &status); err != nil {
+			t.Fatalf("failed to decode status: %v", err)
+		}
+		return svc, status
+	}
+
+	// Removing one of two zones exceeds 25%.
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+	if bc.Counter != 0 {
+		t.Errorf("ReconcileBackends() called %d times, want 0", bc.Counter)
+	}
+	svc, status := getService()
+	if want := []string{"zone1", "zone2"}; !reflect.DeepEqual(status.Zones, want) {
+		t.Errorf("Reconcile() stored zones %v, want %v", status.Zones, want)
+	}
+	if c := meta.FindStatusCondition(svc.Status.Conditions, conditionSynced); c == nil || c.Status != metav1.ConditionFalse || c.Reason != "BackendRemovalBlocked" {
+		t.Errorf("Synced condition = %+v, want False with reason BackendRemovalBlocked", c)
+	}
+
+	// An operator allows the removal once.
+	svc.Annotations[autonegAllowBackendRemovalAnnotation] = "true"
+	if err := r.Update(ctx, svc); err != nil {
+		t.Fatalf("Update() got err: %v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+	if bc.Counter != 1 {
+		t.Errorf("ReconcileBackends() called %d times, want 1", bc.Counter)
+	}
+	svc, status = getService()
+	if want := []string{"zone1"}; !reflect.DeepEqual(status.Zones, want) {
+		t.Errorf("Reconcile() stored zones %v, want %v", status.Zones, want)
+	}
+	if _, ok := svc.Annotations[autonegAllowBackendRemovalAnnotation]; ok {
+		t.Errorf("Service still has the %s annotation", autonegAllowBackendRemovalAnnotation)
+	}
+	if !meta.IsStatusConditionTrue(svc.Status.Conditions, conditionSynced) {
+		t.Errorf("Service is not Synced")
+	}
+}
@@ -26,21 +26,31 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/ingress-gce/pkg/apis/svcneg/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	backoff "github.com/cenkalti/backoff/v5"
 	"github.com/go-logr/logr"
+
+	"github.com/GoogleCloudPlatform/gke-autoneg-controller/api/v1alpha1"
 )
 
 type BackendController interface {
-	ReconcileBackends(context.Context, AutonegStatus, AutonegStatus, bool) error
+	ReconcileBackends(context.Context, AutonegStatus, AutonegStatus, bool) ([]BackendDrift, error)
+	CheckOperations(context.Context, []AutonegOperation) ([]AutonegOperation, error)
 }
 
 // ServiceReconciler reconciles a Service object
@@ -60,15 +70,61 @@ type ServiceReconciler struct {
 
 	MetricBackendServicesPerService *prometheus.GaugeVec
 	MetricNEGsPerService            *prometheus.GaugeVec
+	MetricQuarantinedServices       *prometheus.GaugeVec
+	MetricDriftDetected             *prometheus.CounterVec
 
 	ErrorCount map[string]int
 	MaxErrors  int
+
+	// Backoffs holds the retry backoff of services failing with transient errors
+	Backoffs map[string]*backoff.ExponentialBackOff
+	// PermanentErrors holds the inputs of services which failed with a
+	// permanent error, they are not retried until the inputs change or
+	// permanentErrorExpiry passed
+	PermanentErrors map[string]permanentError
+
+	// ControllerConfigMap references the ConfigMap holding the settings
+	// which apply to all services, if any
+	ControllerConfigMap types.NamespacedName
+	// ClusterName is the name of this cluster in traffic splits
+	ClusterName string
+	// EndpointWeighting enables weighting backends by the ready endpoints
+	// of the service, which watches EndpointSlices
+	EndpointWeighting bool
+	// WatchEndpointZones reconciles services when zones gain their first
+	// or lose their last ready endpoint
+	WatchEndpointZones bool
+	// ZoneSource is where the zones of the NEGs are read from, one of
+	// ZoneSourceSvcNeg, ZoneSourceNEGStatus or ZoneSourceEndpointSlices.
+	// It defaults to the svcneg custom resources if UseSvcNeg is set.
+	ZoneSource string
+	// ProtectMissingSvcNeg keeps the previous zones of a service while NEGs
+	// of its neg-status annotation have no svcneg object, instead of
+	// removing the backends of their zones
+	ProtectMissingSvcNeg bool
+	// MaxBackendRemovalPercent refuses reconciliations which remove more
+	// than this percentage of the backends of a service, or leave a
+	// backend service without backends, 0 disables the guard. Services
+	// can override it with an annotation.
+	MaxBackendRemovalPercent int
+	// NamespacePolicy restricts the backend services of each namespace to
+	// the ones allowed by the AutonegPolicies, if any
+	NamespacePolicy bool
+	// RequireNamespacePolicy denies all backend services while no
+	// AutonegPolicy exists, instead of allowing all of them
+	RequireNamespacePolicy bool
+	// Project is the project of the backend services, as matched by the
+	// AutonegPolicies
+	Project string
 }
 
 //+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;update;patch
 //+kubebuilder:rbac:groups=core,resources=services/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=core,resources=services/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=controller.autoneg.dev,resources=autonegpolicies,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -97,24 +153,55 @@ func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		if apierrors.IsNotFound(err) {
 			// Object not found, return.
 			logger.V(1).Info("Service not found, skipping reconciliation")
-			return r.reconcileResult(logger, errorKey, nil)
+			return r.reconcileResult(ctx, logger, nil, errorKey, nil)
 		}
 		// Error reading thkube object - requeue the request.
 		logger.Error(err, "Failed to get Kubernetes service")
-		return r.reconcileResult(logger, errorKey, err)
+		return r.reconcileResult(ctx, logger, nil, errorKey, err)
 	}
 	logger.V(1).Info("Successfully retrieved Kubernetes service", "serviceType", svc.Spec.Type, "ports", len(svc.Spec.Ports))
 
+	// An operator asked to resume a quarantined service.
+	if _, ok := svc.ObjectMeta.Annotations[autonegResumeAnnotation]; ok {
+		if err = r.resume(ctx, logger, svc, errorKey); err != nil {
+			logger.Error(err, "Failed to resume service")
+			return reconcile.Result{}, err
+		}
+	}
+
+	if isQuarantined(svc) {
+		// Do not touch the GCE API until an operator resumes the service.
+		logger.Info("Service is quarantined, skipping reconciliation", "resumeAnnotation", autonegResumeAnnotation)
+		r.recordQuarantine(svc.Namespace, svc.Name, true)
+		return reconcile.Result{}, nil
+	}
+
 	status, ok, err := getStatuses(ctx, svc.Namespace, svc.Name, svc.ObjectMeta.Annotations, r)
 	// Is this service using autoneg?
 	if !ok {
 		logger.V(1).Info("Service is not using autoneg, skipping")
-		return r.reconcileResult(logger, errorKey, nil)
+		return r.reconcileResult(ctx, logger, svc, errorKey, nil)
+	}
+	var notAllowed *errBackendServiceNotAllowed
+	if errors.As(err, &notAllowed) && !svc.ObjectMeta.DeletionTimestamp.IsZero() {
+		// Removing the NEGs of a deleted service is always allowed.
+		logger.Info("Deregistering NEGs from a backend service the namespace may not use", "error", err.Error())
+		err = nil
 	}
 	if err != nil {
 		logger.Error(err, "Configuration error for service")
 		r.Recorder.Event(svc, "Warning", "ConfigError", err.Error())
-		return r.reconcileResult(logger, errorKey, err)
+		return r.reconcileResult(ctx, logger, svc, errorKey, err)
+	}
+
+	controllerConfig, err := r.controllerConfig(ctx)
+	if err != nil {
+		logger.Error(err, "Failed to read controller config", "configMap", r.ControllerConfigMap)
+		return r.reconcileResult(ctx, logger, svc, errorKey, err)
+	}
+
+	if r.zoneSource() == ZoneSourceSvcNeg {
+		r.reportMissingNEGs(ctx, logger, svc, status.missingNEGs)
 	}
 
 	deleting := false
@@ -124,6 +211,14 @@ func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		deleting = true
 	}
 
+	if perr, ok := r.PermanentErrors[errorKey]; ok {
+		if perr.inputs == r.reconcileInputs(ctx, svc) && time.Since(perr.since) < permanentErrorExpiry {
+			logger.V(1).Info("Service failed with a permanent error and has not changed since, skipping")
+			return reconcile.Result{RequeueAfter: permanentErrorExpiry - time.Since(perr.since)}, nil
+		}
+		delete(r.PermanentErrors, errorKey)
+	}
+
 	intendedStatus := AutonegStatus{
 		AutonegConfig: status.config,
 		NEGStatus:     status.negStatus,
@@ -132,31 +227,142 @@ func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	if status.syncConfig != nil {
 		intendedStatus.AutonegSyncConfig = status.syncConfig
 	}
+	if !deleting {
+		intendedStatus.Drain = newDrain(controllerConfig, status.status.Drain)
+	}
+	if !deleting && status.config.countsEndpoints() {
+		if status.config.weightsByEndpoints() && !r.EndpointWeighting {
+			err = fmt.Errorf("%w: weight_by_endpoints requires the controller to run with --endpoint-weighting", errConfigInvalid)
+			r.Recorder.Event(svc, "Warning", "ConfigError", err.Error())
+			return r.reconcileResult(ctx, logger, svc, errorKey, err)
+		}
+		if intendedStatus.Endpoints, err = r.readyEndpoints(ctx, svc); err != nil {
+			logger.Error(err, "Failed to read endpoints")
+			return r.reconcileResult(ctx, logger, svc, errorKey, err)
+		}
+	}
 	if err = r.RecordMetrics(logger, svc.ObjectMeta.Namespace, svc.ObjectMeta.Name, status); err != nil {
 		logger.Error(err, "Error recording metrics")
 	}
 
+	// Compute operations started by a previous reconciliation have to finish
+	// before doing any further work.
+	if len(status.status.Operations) > 0 {
+		pending, err := r.CheckOperations(ctx, status.status.Operations)
+		if err == nil && len(pending) > 0 {
+			logger.Info("Waiting for compute operations", "operations", len(pending))
+			return r.waitForOperations(ctx, logger, svc, status.status, pending)
+		}
+		status.status.Operations = nil
+		if err != nil {
+			logger.Info("BackendError when checking compute operations", "service", svc, "error", err.Error())
+			// Forget the failed operations, the next reconciliation applies
+			// the intended status again.
+			if _, uerr := r.waitForOperations(ctx, logger, svc, status.status, nil); uerr != nil {
+				logger.Error(uerr, "Failed to clear compute operations")
+			}
+			return r.backendError(ctx, logger, svc, errorKey, "BackendError", err, deleting)
+		}
+	}
+
+	// Split backend services are adjusted to the capacity of the other
+	// clusters, which can change at any time.
+	var shares map[string]float64
+	if !deleting {
+		shares = controllerConfig.TrafficSplit.shares(r.ClusterName, status.config)
+	}
 	if deleting {
 		intendedStatus.BackendServices = make(map[string]map[string]AutonegNEGConfig, 0)
-	} else if reflect.DeepEqual(status.status, intendedStatus) && !r.AlwaysReconcile {
+	} else if reflect.DeepEqual(status.status, intendedStatus) && !r.AlwaysReconcile && len(shares) == 0 {
 		// Equal, no reconciliation necessary
-		return r.reconcileResult(logger, errorKey, nil)
+		if status.allowBackendRemoval || clearPlan(svc) {
+			// The override and a plan are not needed, they must not apply
+			// to a later reconciliation.
+			delete(svc.ObjectMeta.Annotations, autonegAllowBackendRemovalAnnotation)
+			if err = r.Update(ctx, svc); err != nil {
+				return r.reconcileResult(ctx, logger, svc, errorKey, err)
+			}
+		}
+		res, err := r.reconcileResult(ctx, logger, svc, errorKey, nil)
+		if err == nil && len(status.missingNEGs) > 0 {
+			res = requeueWithin(res, missingNEGsRequeue)
+		}
+		return res, err
+	}
+
+	// Refuse to remove too many backends at once, e.g. because of truncated
+	// zones, unless an operator allowed it.
+	if !deleting && status.maxBackendRemoval > 0 && !status.allowBackendRemoval {
+		if reason := countBackendRemoval(status.status, intendedStatus).exceeds(status.maxBackendRemoval); reason != "" {
+			return r.blockBackendRemoval(ctx, logger, svc, errorKey, reason)
+		}
 	}
 
 	// Reconcile differences
 	logger.Info("Applying intended status", "status", intendedStatus)
 
-	if err = r.ReconcileBackends(ctx, status.status, intendedStatus, deleting); err != nil {
+	intendedStatus.driftPolicy = status.driftPolicy
+	intendedStatus.drainStep = controllerConfig.DrainStep
+	intendedStatus.trafficSplit = shares
+	intendedStatus.serviceKey = errorKey
+	intendedStatus.changePriority = status.changePriority
+	intendedStatus.frozen = controllerConfig.Freeze
+	// Changes of protected backend services wait for an operator to approve
+	// their plan.
+	intendedStatus.protected = controllerConfig.ProtectedBackendServices
+	intendedStatus.approvedPlan = approvedPlan(svc)
+	var window string
+	var windowEnd time.Time
+	if !deleting {
+		window, windowEnd, intendedStatus.deferChanges = controllerConfig.FreezeWindows.active(svc.Namespace, time.Now())
+	}
+	drift, err := r.ReconcileBackends(ctx, status.status, intendedStatus, deleting)
+	if !deleting && status.driftPolicy != "" {
+		r.reportDrift(ctx, logger, svc, status.driftPolicy, drift)
+	}
+	var drainErr *errDrainInProgress
+	if errors.As(err, &drainErr) && intendedStatus.Drain != nil {
+		// The step was applied, the next one follows after the interval.
+		logger.Info("Drain in progress", "draining", drainErr.Draining, "restoring", drainErr.Restoring, "waitingForHealth", drainErr.WaitingForHealth)
+		intendedStatus.Drain.InProgress = true
+		err = nil
+	}
+	if intendedStatus.Drain.empty() {
+		intendedStatus.Drain = nil
+	}
+	if err != nil {
+		var pendingErr *errOperationsPending
+		if errors.As(err, &pendingErr) {
+			logger.Info("Waiting for compute operations", "operations", len(pendingErr.Operations))
+			// Keep the capacities recorded for draining backends.
+			pendingStatus := status.status
+			pendingStatus.Drain = pendingDrain(status.status.Drain, intendedStatus.Drain)
+			return r.waitForOperations(ctx, logger, svc, pendingStatus, pendingErr.Operations)
+		}
+		var frozenErr *errMutationsFrozen
+		if errors.As(err, &frozenErr) {
+			return r.waitForUnfreeze(ctx, logger, svc, errorKey, frozenErr)
+		}
+		var deferredErr *errChangesDeferred
+		if errors.As(err, &deferredErr) {
+			return r.deferChanges(ctx, logger, svc, errorKey, deferredErr, window, windowEnd)
+		}
+		var approvalErr *errApprovalRequired
+		if errors.As(err, &approvalErr) {
+			return r.awaitApproval(ctx, logger, svc, errorKey, approvalErr.Plan)
+		}
+		var budgetErr *errChangeBudgetExhausted
+		if errors.As(err, &budgetErr) {
+			return r.waitForChangeBudget(ctx, logger, svc, errorKey, budgetErr)
+		}
 		var e *errNotFound
 		if !(deleting && errors.As(err, &e)) {
 			logger.Info("BackendError when reconciling backends during normal operations", "service", svc, "error", err.Error())
-			r.Recorder.Event(svc, "Warning", "BackendError", err.Error())
-			return r.reconcileResult(logger, errorKey, err)
+			return r.backendError(ctx, logger, svc, errorKey, "BackendError", err, deleting)
 		}
 		if deleting {
 			logger.Info("BackendError when reconciling backends during deletion", "service", svc, "error", err.Error())
-			r.Recorder.Event(svc, "Warning", "BackendError while deleting", err.Error())
-			return r.reconcileResult(logger, errorKey, err)
+			return r.backendError(ctx, logger, svc, errorKey, "BackendError while deleting", err, deleting)
 		}
 	}
 
@@ -177,9 +383,14 @@ func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		anStatus, err := json.Marshal(intendedStatus)
 		if err != nil {
 			logger.Error(err, "json marshal error")
-			return r.reconcileResult(logger, errorKey, err)
+			return r.reconcileResult(ctx, logger, svc, errorKey, err)
 		}
 		svc.ObjectMeta.Annotations[autonegStatusAnnotation] = string(anStatus)
+		if status.allowBackendRemoval {
+			logger.Info("Removing backend removal override", "annotation", autonegAllowBackendRemovalAnnotation)
+			delete(svc.ObjectMeta.Annotations, autonegAllowBackendRemovalAnnotation)
+		}
+		clearPlan(svc)
 	}
 
 	if err = r.Update(ctx, svc); err != nil {
@@ -189,7 +400,17 @@ func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			return reconcile.Result{RequeueAfter: 1 * time.Second}, nil
 		}
 		r.Recorder.Event(svc, "Warning", "BackendError", err.Error())
-		return r.reconcileResult(logger, errorKey, err)
+		return r.reconcileResult(ctx, logger, svc, errorKey, err)
+	}
+
+	if !deleting {
+		if err = r.setCondition(ctx, svc, conditionSynced, metav1.ConditionTrue, "Synced", "Backends are in sync"); err != nil {
+			logger.Error(err, "Failed to update service status")
+		}
+		if err = r.removeCondition(ctx, svc, conditionChangesDeferred); err != nil {
+			logger.Error(err, "Failed to update service status")
+		}
+		r.reportDrain(ctx, logger, svc, intendedStatus.Drain, drainErr)
 	}
 
 	for port, endpointGroups := range intendedStatus.BackendServices {
@@ -211,7 +432,134 @@ func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		}
 	}
 
-	return r.reconcileResult(logger, errorKey, nil)
+	res, err := r.reconcileResult(ctx, logger, svc, errorKey, nil)
+	if err == nil && drainErr != nil {
+		res.RequeueAfter = controllerConfig.DrainInterval
+	} else if err == nil && len(shares) > 0 {
+		res = requeueWithin(res, controllerConfig.TrafficSplitInterval)
+	}
+	if err == nil && len(status.missingNEGs) > 0 {
+		res = requeueWithin(res, missingNEGsRequeue)
+	}
+	return res, err
+}
+
+// waitForChangeBudget records a change queued for the change budget as an
+// event and the Synced condition, and retries it once the budget allows.
+func (r *ServiceReconciler) waitForChangeBudget(ctx context.Context, logger logr.Logger, svc *corev1.Service, errorKey string, budgetErr *errChangeBudgetExhausted) (reconcile.Result, error) {
+	logger.Info("Change budget exhausted, queueing change", "backendService", budgetErr.BackendService, "position", budgetErr.Position, "retryAfter", budgetErr.RetryAfter)
+	r.Recorder.Event(svc, "Normal", "ChangeQueued", budgetErr.Error())
+	if err := r.setCondition(ctx, svc, conditionSynced, metav1.ConditionFalse, "ChangeBudgetExhausted", "Backend service changes are queued for the change budget of the controller"); err != nil {
+		logger.Error(err, "Failed to update service status")
+	}
+	res, err := r.reconcileResult(ctx, logger, svc, errorKey, nil)
+	res.RequeueAfter = budgetErr.RetryAfter
+	return res, err
+}
+
+// backendError records a failed backend reconciliation as an event and the
+// Synced condition. Transient errors are retried with a per-service
+// exponential backoff, permission errors every retryMaxInterval and
+// permanent errors after permanentErrorExpiry, unless the service changes
+// earlier.
+func (r *ServiceReconciler) backendError(ctx context.Context, logger logr.Logger, svc *corev1.Service, errorKey string, eventReason string, err error, deleting bool) (reconcile.Result, error) {
+	r.Recorder.Event(svc, "Warning", eventReason, err.Error())
+	class, permanent := classifyError(err)
+	if deleting && class == errorClassNotFound {
+		// Not found errors are expected while deleting.
+		permanent = false
+	}
+	reason := "BackendError"
+	if class != errorClassUnknown {
+		reason = class
+	}
+	if cerr := r.setCondition(ctx, svc, conditionSynced, metav1.ConditionFalse, reason, err.Error()); cerr != nil {
+		logger.Error(cerr, "Failed to update service status")
+	}
+
+	res, err := r.reconcileResult(ctx, logger, svc, errorKey, err)
+	if err == nil {
+		// The service was quarantined.
+		return res, nil
+	}
+	if permanent {
+		if r.PermanentErrors == nil {
+			r.PermanentErrors = make(map[string]permanentError, 0)
+		}
+		r.PermanentErrors[errorKey] = permanentError{inputs: r.reconcileInputs(ctx, svc), since: time.Now()}
+		logger.Info("Not retrying permanent backend error until the service changes", "reason", reason, "retryAfter", permanentErrorExpiry.String(), "error", err.Error())
+		return reconcile.Result{RequeueAfter: permanentErrorExpiry}, nil
+	}
+	if r.Backoffs == nil {
+		r.Backoffs = make(map[string]*backoff.ExponentialBackOff, 0)
+	}
+	b, ok := r.Backoffs[errorKey]
+	if !ok {
+		b = backoff.NewExponentialBackOff()
+		b.InitialInterval = retryInitialInterval
+		b.MaxInterval = retryMaxInterval
+		r.Backoffs[errorKey] = b
+	}
+	retryAfter := b.NextBackOff()
+	if class == errorClassPermissionDenied {
+		// Permissions are granted out-of-band, retry at the longest
+		// interval until they are.
+		retryAfter = retryMaxInterval
+	}
+	logger.Info("Retrying transient backend error", "reason", reason, "retryAfter", retryAfter.String(), "error", err.Error())
+	return reconcile.Result{RequeueAfter: retryAfter}, nil
+}
+
+// permanentError records the inputs of a reconciliation which failed with a
+// permanent error
+type permanentError struct {
+	inputs string
+	since  time.Time
+}
+
+// reconcileInputs identifies what a reconciliation of the service acts
+// upon: the service, the controller config and the AutonegPolicies
+func (r *ServiceReconciler) reconcileInputs(ctx context.Context, svc *corev1.Service) string {
+	inputs := serviceHash(svc)
+	if config, err := r.controllerConfig(ctx); err == nil {
+		inputs += " config=" + config.Version
+	}
+	if r.NamespacePolicy {
+		policies := &v1alpha1.AutonegPolicyList{}
+		if err := r.List(ctx, policies); err == nil {
+			for _, policy := range policies.Items {
+				inputs += fmt.Sprintf(" %s=%d", policy.Name, policy.Generation)
+			}
+		}
+	}
+	return inputs
+}
+
+// waitForOperations stores the compute operations in progress along with the
+// previously reconciled status and requeues the service to check them again.
+// Keeping the previous status makes the next reconciliation compute the same
+// changes, which are no-ops once the operations have finished.
+func (r *ServiceReconciler) waitForOperations(ctx context.Context, logger logr.Logger, svc *corev1.Service, actual AutonegStatus, ops []AutonegOperation) (reconcile.Result, error) {
+	actual.Operations = ops
+	if svc.ObjectMeta.DeletionTimestamp.IsZero() && !containsString(svc.ObjectMeta.Finalizers, autonegFinalizer) {
+		// Backend services were already patched, make sure to clean them up.
+		logger.Info("Adding finalizer")
+		svc.ObjectMeta.Finalizers = append(svc.ObjectMeta.Finalizers, autonegFinalizer)
+	}
+	anStatus, err := json.Marshal(actual)
+	if err != nil {
+		logger.Error(err, "json marshal error")
+		return reconcile.Result{}, err
+	}
+	svc.ObjectMeta.Annotations[autonegStatusAnnotation] = string(anStatus)
+	if err = r.Update(ctx, svc); err != nil {
+		if apierrors.IsConflict(err) {
+			logger.Info("Conflict updating service; requeueing", "error", err.Error())
+			return reconcile.Result{RequeueAfter: 1 * time.Second}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: operationPollInterval}, nil
 }
 
 func (r *ServiceReconciler) RegisterMetrics() {
@@ -230,7 +578,38 @@ func (r *ServiceReconciler) RegisterMetrics() {
 		},
 		[]string{"namespace", "service"},
 	)
-	metrics.Registry.MustRegister(r.MetricBackendServicesPerService, r.MetricNEGsPerService)
+
+	r.MetricQuarantinedServices = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "quarantined_services",
+			Help: "Services quarantined after exceeding the maximum number of consecutive errors",
+		},
+		[]string{"namespace", "service"},
+	)
+
+	r.MetricDriftDetected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "autoneg_drift_detected_total",
+			Help: "Number of backends found changed outside of autoneg",
+		},
+		[]string{"namespace", "service", "kind"},
+	)
+	metrics.Registry.MustRegister(r.MetricBackendServicesPerService, r.MetricNEGsPerService, r.MetricQuarantinedServices, r.MetricDriftDetected)
+}
+
+func (r *ServiceReconciler) recordQuarantine(namespace string, service string, quarantined bool) {
+	if r.MetricQuarantinedServices == nil {
+		return
+	}
+	metricLabels := prometheus.Labels{
+		"namespace": namespace,
+		"service":   service,
+	}
+	if quarantined {
+		(*r.MetricQuarantinedServices).With(metricLabels).Set(1)
+	} else {
+		(*r.MetricQuarantinedServices).Delete(metricLabels)
+	}
 }
 
 func (r *ServiceReconciler) RecordMetrics(logger logr.Logger, namespace string, service string, status Statuses) error {
@@ -260,15 +639,34 @@ func (r *ServiceReconciler) RecordMetrics(logger logr.Logger, namespace string,
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Service{})
 	if r.UseSvcNeg {
-		return ctrl.NewControllerManagedBy(mgr).
-			For(&corev1.Service{}).
-			Owns(&v1beta1.ServiceNetworkEndpointGroup{}).
-			Complete(r)
-	}
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Service{}).
-		Complete(r)
+		b = b.Owns(&v1beta1.ServiceNetworkEndpointGroup{})
+	}
+	if r.ControllerConfigMap.Name != "" {
+		// Reconcile all services when the controller settings change
+		b = b.Watches(&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.managedServices),
+			builder.WithPredicates(predicate.NewPredicateFuncs(r.isControllerConfigMap)))
+	}
+	if r.NamespacePolicy {
+		// Check all services against changed policies
+		b = b.Watches(&v1alpha1.AutonegPolicy{},
+			handler.EnqueueRequestsFromMapFunc(r.managedServices))
+	}
+	if r.EndpointWeighting {
+		// Reconcile services weighted by endpoints when their endpoints change
+		b = b.Watches(&discoveryv1.EndpointSlice{},
+			handler.EnqueueRequestsFromMapFunc(r.endpointSliceService))
+	}
+	if r.WatchEndpointZones || r.zoneSource() == ZoneSourceEndpointSlices {
+		// Reconcile services when zones gain or lose their ready endpoints
+		b = b.Watches(&discoveryv1.EndpointSlice{},
+			handler.EnqueueRequestsFromMapFunc(r.endpointZonesService),
+			builder.WithPredicates(predicate.Funcs{UpdateFunc: endpointZonesChanged}))
+	}
+	return b.Complete(r)
 }
 
 // Helper functions to check and remove string from a slice of strings.
@@ -291,19 +689,60 @@ func removeString(slice []string, s string) (result []string) {
 	return
 }
 
-func (r *ServiceReconciler) reconcileResult(logger logr.Logger, errorKey string, err error) (reconcile.Result, error) {
+func (r *ServiceReconciler) reconcileResult(ctx context.Context, logger logr.Logger, svc *corev1.Service, errorKey string, err error) (reconcile.Result, error) {
 	if err != nil {
 		r.ErrorCount[errorKey]++
 		if r.MaxErrors > 0 && r.ErrorCount[errorKey] > r.MaxErrors {
 			logger.Error(err, "Maximum error count exceeded for service.", "service", errorKey)
+			if svc != nil {
+				if qerr := r.quarantine(ctx, svc, err); qerr != nil {
+					logger.Error(qerr, "Failed to quarantine service", "service", errorKey)
+					return reconcile.Result{}, qerr
+				}
+				r.ErrorCount[errorKey] = 0
+				// A quarantined service is not requeued until it is resumed.
+				return reconcile.Result{}, nil
+			}
 			err = nil
 		}
 	}
 	if err == nil {
 		r.ErrorCount[errorKey] = 0
+		delete(r.Backoffs, errorKey)
 	}
 	if r.ReconcileDuration != nil && r.AlwaysReconcile {
 		return reconcile.Result{RequeueAfter: *r.ReconcileDuration}, err
 	}
 	return reconcile.Result{}, err
 }
+
+// quarantine marks the service with the Quarantined condition, so it is no
+// longer reconciled until the resume annotation is set.
+func (r *ServiceReconciler) quarantine(ctx context.Context, svc *corev1.Service, cause error) error {
+	message := fmt.Sprintf("Exceeded %d consecutive errors, last error: %s; set the %s annotation to resume", r.MaxErrors, cause.Error(), autonegResumeAnnotation)
+	if err := r.setCondition(ctx, svc, conditionQuarantined, metav1.ConditionTrue, "MaximumErrorsExceeded", message); err != nil {
+		return err
+	}
+	r.Recorder.Event(svc, "Warning", "Quarantined", message)
+	r.recordQuarantine(svc.Namespace, svc.Name, true)
+	return nil
+}
+
+// resume clears the Quarantined condition and removes the resume annotation.
+func (r *ServiceReconciler) resume(ctx context.Context, logger logr.Logger, svc *corev1.Service, errorKey string) error {
+	wasQuarantined := isQuarantined(svc)
+	if err := r.removeCondition(ctx, svc, conditionQuarantined); err != nil {
+		return err
+	}
+	delete(svc.ObjectMeta.Annotations, autonegResumeAnnotation)
+	if err := r.Update(ctx, svc); err != nil {
+		return err
+	}
+	r.ErrorCount[errorKey] = 0
+	r.recordQuarantine(svc.Namespace, svc.Name, false)
+	if wasQuarantined {
+		logger.Info("Resumed quarantined service")
+		r.Recorder.Event(svc, "Normal", "Resumed", "Service resumed from quarantine")
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestReconciler(objs ...*corev1.Service) *ServiceReconciler {
+	builder := fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithStatusSubresource(&corev1.Service{})
+	for _, obj := range objs {
+		builder = builder.WithObjects(obj)
+	}
+	return &ServiceReconciler{
+		Client:              builder.Build(),
+		BackendController:   &TestBackendController{},
+		Recorder:            record.NewFakeRecorder(100),
+		ServiceNameTemplate: serviceNameTemplate,
+		AllowServiceName:    true,
+		ErrorCount:          make(map[string]int, 0),
+	}
+}
+
+func TestReconcileQuarantineAndResume(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	r := newTestReconciler(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   key.Namespace,
+			Name:        key.Name,
+			Annotations: map[string]string{autonegAnnotation: malformedJSON},
+		},
+	})
+	r.MaxErrors = 1
+	req := ctrl.Request{NamespacedName: key}
+
+	if _, err := r.Reconcile(ctx, req); err == nil {
+		t.Fatalf("Reconcile() got no error for an invalid config, want one")
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile() got err: %v, want the service to be quarantined", err)
+	}
+
+	svc := &corev1.Service{}
+	if err := r.Get(ctx, key, svc); err != nil {
+		t.Fatalf("Get() got err: %v", err)
+	}
+	if !isQuarantined(svc) {
+		t.Fatalf("Service is not quarantined, conditions: %+v", svc.Status.Conditions)
+	}
+
+	// A quarantined service is skipped without counting further errors.
+	res, err := r.Reconcile(ctx, req)
+	if err != nil || res.RequeueAfter != 0 {
+		t.Fatalf("Reconcile() of quarantined service got %+v, %v, want no requeue", res, err)
+	}
+	if r.ErrorCount[key.String()] != 0 {
+		t.Errorf("ErrorCount = %d, want 0", r.ErrorCount[key.String()])
+	}
+
+	svc.Annotations[autonegResumeAnnotation] = "true"
+	if err := r.Update(ctx, svc); err != nil {
+		t.Fatalf("Update() got err: %v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err == nil {
+		t.Fatalf("Reconcile() after resume got no error for an invalid config, want one")
+	}
+	if err := r.Get(ctx, key, svc); err != nil {
+		t.Fatalf("Get() got err: %v", err)
+	}
+	if isQuarantined(svc) {
+		t.Errorf("Service is still quarantined after resume")
+	}
+	if _, ok := svc.Annotations[autonegResumeAnnotation]; ok {
+		t.Errorf("Resume annotation was not removed")
+	}
+}
+
+type fakeOperationsBackendController struct {
+	ops        []AutonegOperation
+	checks     int
+	reconciled int
+}
+
+func (f *fakeOperationsBackendController) ReconcileBackends(ctx context.Context, actual, intended AutonegStatus, deleting bool) ([]BackendDrift, error) {
+	f.reconciled++
+	if f.reconciled == 1 {
+		return nil, &errOperationsPending{Operations: f.ops}
+	}
+	return nil, nil
+}
+
+func (f *fakeOperationsBackendController) CheckOperations(ctx context.Context, ops []AutonegOperation) ([]AutonegOperation, error) {
+	f.checks++
+	if f.checks == 1 {
+		return ops, nil
+	}
+	return nil, nil
+}
+
+func TestReconcileWaitsForOperations(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	r := newTestReconciler(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   key.Namespace,
+			Name:        key.Name,
+			Annotations: map[string]string{autonegAnnotation: validConfig},
+		},
+	})
+	bc := &fakeOperationsBackendController{ops: []AutonegOperation{{Name: "op-1", BackendService: "http-be"}}}
+	r.BackendController = bc
+	req := ctrl.Request{NamespacedName: key}
+
+	getStatus := func() AutonegStatus {
+		svc := &corev1.Service{}
+		if err := r.Get(ctx, key, svc); err != nil {
+			t.Fatalf("Get() got err: %v", err)
+		}
+		var status AutonegStatus
+		if err := json.Unmarshal([]byte(svc.Annotations[autonegStatusAnnotation]), &status); err != nil {
+			t.Fatalf("failed to decode status: %v", err)
+		}
+		if !containsString(svc.Finalizers, autonegFinalizer) {
+			t.Errorf("Service has no finalizer")
+		}
+		return status
+	}
+
+	for i := 0; i < 2; i++ {
+		res, err := r.Reconcile(ctx, req)
+		if err != nil || res.RequeueAfter != operationPollInterval {
+			t.Fatalf("Reconcile() #%d got %+v, %v, want requeue after %v", i+1, res, err, operationPollInterval)
+		}
+		status := getStatus()
+		if !reflect.DeepEqual(status.Operations, bc.ops) {
+			t.Errorf("Reconcile() #%d stored operations %+v, want %+v", i+1, status.Operations, bc.ops)
+		}
+		if len(status.BackendServices) != 0 {
+			t.Errorf("Reconcile() #%d stored intended status before operations finished", i+1)
+		}
+	}
+	if bc.reconciled != 1 {
+		t.Errorf("ReconcileBackends() called %d times while operations were pending, want 1", bc.reconciled)
+	}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+	status := getStatus()
+	if len(status.Operations) != 0 || len(status.BackendServices) != 2 {
+		t.Errorf("Reconcile() stored status %+v, want intended status without operations", status)
+	}
+}
+
+type fakeErrorBackendController struct {
+	err        error
+	reconciled int
+}
+
+func (f *fakeErrorBackendController) ReconcileBackends(ctx context.Context, actual, intended AutonegStatus, deleting bool) ([]BackendDrift, error) {
+	f.reconciled++
+	return nil, f.err
+}
+
+func (f *fakeErrorBackendController) CheckOperations(ctx context.Context, ops []AutonegOperation) ([]AutonegOperation, error) {
+	return nil, nil
+}
+
+func TestReconcileRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	req := ctrl.Request{NamespacedName: key}
+	newService := func() *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   key.Namespace,
+				Name:        key.Name,
+				Annotations: map[string]string{autonegAnnotation: validConfig},
+			},
+		}
+	}
+
+	t.Run("transient errors are retried with backoff", func(t *testing.T) {
+		r := newTestReconciler(newService())
+		r.BackendController = &fakeErrorBackendController{err: &googleapi.Error{Code: http.StatusServiceUnavailable}}
+		res, err := r.Reconcile(ctx, req)
+		if err != nil || res.RequeueAfter <= 0 || res.RequeueAfter > retryInitialInterval*2 {
+			t.Errorf("Reconcile() got %+v, %v, want requeue after about %v", res, err, retryInitialInterval)
+		}
+		if r.ErrorCount[key.String()] != 1 {
+			t.Errorf("ErrorCount = %d, want 1", r.ErrorCount[key.String()])
+		}
+	})
+
+	t.Run("permission errors are retried at the longest interval", func(t *testing.T) {
+		r := newTestReconciler(newService())
+		bc := &fakeErrorBackendController{err: &googleapi.Error{Code: http.StatusForbidden}}
+		r.BackendController = bc
+		for i := 0; i < 2; i++ {
+			res, err := r.Reconcile(ctx, req)
+			if err != nil || res.RequeueAfter != retryMaxInterval {
+				t.Errorf("Reconcile() #%d got %+v, %v, want requeue after %v", i+1, res, err, retryMaxInterval)
+			}
+		}
+		if bc.reconciled != 2 {
+			t.Errorf("ReconcileBackends() called %d times, want 2", bc.reconciled)
+		}
+		if _, ok := r.PermanentErrors[key.String()]; ok {
+			t.Errorf("PermanentErrors recorded a permission error")
+		}
+	})
+
+	t.Run("permanent errors are not retried until the service changes", func(t *testing.T) {
+		r := newTestReconciler(newService())
+		bc := &fakeErrorBackendController{err: &googleapi.Error{Code: http.StatusBadRequest}}
+		r.BackendController = bc
+		for i := 0; i < 2; i++ {
+			// The error is retried once it expires.
+			res, err := r.Reconcile(ctx, req)
+			if err != nil || res.RequeueAfter <= permanentErrorExpiry-time.Minute || res.RequeueAfter > permanentErrorExpiry {
+				t.Errorf("Reconcile() #%d got %+v, %v, want requeue after about %v", i+1, res, err, permanentErrorExpiry)
+			}
+		}
+		if bc.reconciled != 1 {
+			t.Errorf("ReconcileBackends() called %d times, want 1", bc.reconciled)
+		}
+
+		svc := &corev1.Service{}
+		if err := r.Get(ctx, key, svc); err != nil {
+			t.Fatalf("Get() got err: %v", err)
+		}
+		cond := meta.FindStatusCondition(svc.Status.Conditions, conditionSynced)
+		if cond == nil || cond.Reason != errorClassInvalidField {
+			t.Errorf("Synced condition = %+v, want reason %s", cond, errorClassInvalidField)
+		}
+		svc.Annotations[autonegAnnotation] = validMultiConfig
+		if err := r.Update(ctx, svc); err != nil {
+			t.Fatalf("Update() got err: %v", err)
+		}
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatalf("Reconcile() got err: %v", err)
+		}
+		if bc.reconciled != 2 {
+			t.Errorf("ReconcileBackends() called %d times after the service changed, want 2", bc.reconciled)
+		}
+	})
+
+	t.Run("permanent errors are retried after the controller config changes", func(t *testing.T) {
+		r := newTestReconciler(newService())
+		bc := &fakeErrorBackendController{err: &googleapi.Error{Code: http.StatusBadRequest}}
+		r.BackendController = bc
+		r.ControllerConfigMap = types.NamespacedName{Namespace: "autoneg-system", Name: "autoneg-controller-config"}
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: r.ControllerConfigMap.Namespace, Name: r.ControllerConfigMap.Name},
+			Data:       map[string]string{controllerConfigDrainCluster: "true"},
+		}
+		if err := r.Create(ctx, cm); err != nil {
+			t.Fatalf("Create() got err: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := r.Reconcile(ctx, req); err != nil {
+				t.Fatalf("Reconcile() #%d got err: %v", i+1, err)
+			}
+		}
+		if bc.reconciled != 1 {
+			t.Errorf("ReconcileBackends() called %d times, want 1", bc.reconciled)
+		}
+
+		cm.Data[controllerConfigDrainCluster] = "false"
+		if err := r.Update(ctx, cm); err != nil {
+			t.Fatalf("Update() got err: %v", err)
+		}
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatalf("Reconcile() got err: %v", err)
+		}
+		if bc.reconciled != 2 {
+			t.Errorf("ReconcileBackends() called %d times after the controller config changed, want 2", bc.reconciled)
+		}
+
+		// Permanent errors expire, e.g. after a fix out-of-band.
+		perr := r.PermanentErrors[key.String()]
+		perr.since = perr.since.Add(-permanentErrorExpiry)
+		r.PermanentErrors[key.String()] = perr
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatalf("Reconcile() got err: %v", err)
+		}
+		if bc.reconciled != 3 {
+			t.Errorf("ReconcileBackends() called %d times after the permanent error expired, want 3", bc.reconciled)
+		}
+	})
+}
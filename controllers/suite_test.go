@@ -95,7 +95,7 @@ var _ = BeforeSuite(func() {
 		option.WithEndpoint(fakeServer.URL), option.WithoutAuthentication())
 
 	backendController = &TestBackendController{Counter: 0,
-		BackendController: NewBackendController(projectTestName, service),
+		BackendController: NewBackendController(projectTestName, service, BackendControllerOptions{}),
 	}
 	duration := 1 * time.Second
 
@@ -125,14 +125,14 @@ type TestBackendController struct {
 	Counter int
 }
 
-func (t *TestBackendController) ReconcileBackends(ctx context.Context, as AutonegStatus, is AutonegStatus, deleting bool) error {
+func (t *TestBackendController) ReconcileBackends(ctx context.Context, as AutonegStatus, is AutonegStatus, deleting bool) ([]BackendDrift, error) {
 	t.Counter++
 	// Use controller logger for better test output control
 	logf.Log.WithName("test-backend-controller").Info("ReconcileBackends called", "counter", t.Counter)
 	if t.BackendController != nil {
 		return t.BackendController.ReconcileBackends(ctx, as, is, deleting)
 	}
-	return nil
+	return nil, nil
 }
 
 var _ = AfterSuite(func() {
@@ -375,7 +375,14 @@ func (fbss *fakeBackendServiceServer) ServeHTTP(w http.ResponseWriter, r *http.R
 		logf("ServeHTTP patch received: %+v\n%s\n", patchBody.Backends, body.String())
 		bs.Backends = patchBody.Backends
 
-		if err := json.NewEncoder(w).Encode(bs); err != nil {
+		// The patch returns an operation named after the backend service,
+		// its status is taken from the expected operation statuses.
+		opStatus := computeOperationStatusDone
+		if ops, ok := fbss.bsOperationStatuses[name]; ok && len(ops) > 0 {
+			opStatus = ops[0]
+			fbss.bsOperationStatuses[name] = ops[1:]
+		}
+		if err := json.NewEncoder(w).Encode(compute.Operation{Name: name, Status: opStatus}); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			logf("ServeHTTP: response code: %v\n", http.StatusInternalServerError)
 			fatalf("json encode failed: %v", err)
@@ -0,0 +1,184 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// negRE matches the project, zone and name of a zonal NEG URL
+var negRE = regexp.MustCompile(`projects/([^/]+)/zones/([^/]+)/networkEndpointGroups/([^/]+)$`)
+
+// TrafficSplit holds the weights of the clusters sharing backend services,
+// keyed by backend service name, or region and name separated by a slash for
+// regional backend services
+type TrafficSplit map[string]map[string]float64
+
+// parseTrafficSplit parses and validates a traffic split
+func parseTrafficSplit(s string) (TrafficSplit, error) {
+	var split TrafficSplit
+	if err := json.Unmarshal([]byte(s), &split); err != nil {
+		return nil, fmt.Errorf("%w: %s is not valid JSON: %v", errConfigInvalid, controllerConfigTrafficSplit, err)
+	}
+	for key, weights := range split {
+		var total float64
+		for cluster, weight := range weights {
+			if weight < 0 {
+				return nil, fmt.Errorf("%w: %s of cluster %q for %q must not be negative", errConfigInvalid, controllerConfigTrafficSplit, cluster, key)
+			}
+			total += weight
+		}
+		if total == 0 {
+			return nil, fmt.Errorf("%w: %s for %q needs a positive weight", errConfigInvalid, controllerConfigTrafficSplit, key)
+		}
+	}
+	return split, nil
+}
+
+// trafficSplitKey returns the key of a backend service in a traffic split
+func trafficSplitKey(region, name string) string {
+	if region == "" {
+		return name
+	}
+	return region + "/" + name
+}
+
+// shares returns the share of traffic of the cluster for the backend
+// services of the configuration which are split. Backend services whose
+// split does not list the cluster are left alone.
+func (split TrafficSplit) shares(cluster string, config AutonegConfig) map[string]float64 {
+	if cluster == "" || len(split) == 0 {
+		return nil
+	}
+	var shares map[string]float64
+	for _, cfgs := range config.BackendServices {
+		for _, cfg := range cfgs {
+			key := trafficSplitKey(cfg.Region, cfg.Name)
+			weights, ok := split[key]
+			if !ok {
+				continue
+			}
+			weight, ok := weights[cluster]
+			if !ok {
+				continue
+			}
+			var total float64
+			for _, w := range weights {
+				total += w
+			}
+			if shares == nil {
+				shares = make(map[string]float64)
+			}
+			shares[key] = weight / total
+		}
+	}
+	return shares
+}
+
+// splitCapacity returns the capacity scaler for the backends of this cluster
+// which gives them the share of the traffic of the backend service. The
+// capacity of a backend is its capacity scaler times its maximum rate or
+// connections, or the endpoints of its NEG times the maximum rate or
+// connections per endpoint. The capacity scaler is
+// chosen so the capacity of this cluster relates to the current capacity of
+// the other backends as the share relates to the rest, and scaled so the
+// largest capacity scaler of the backend service is 1. As every cluster does
+// the same, the clusters converge to the split.
+func (b *ProdBackendController) splitCapacity(ctx context.Context, svc *compute.BackendService, backends []compute.Backend, share float64) (float64, error) {
+	if share <= 0 {
+		return 0, nil
+	}
+	if share >= 1 {
+		return 1, nil
+	}
+	own := make(map[string]bool, len(backends))
+	var ownCapacity float64
+	for _, u := range backends {
+		own[u.Group] = true
+		endpoints, err := b.negSize(ctx, u.Group)
+		if err != nil {
+			return 0, err
+		}
+		ownCapacity += backendCapacity(u, endpoints)
+	}
+	var otherCapacity, maxScaler float64
+	for _, be := range svc.Backends {
+		if own[be.Group] {
+			continue
+		}
+		endpoints, err := b.negSize(ctx, be.Group)
+		if err != nil {
+			return 0, err
+		}
+		otherCapacity += be.CapacityScaler * backendCapacity(*be, endpoints)
+		maxScaler = math.Max(maxScaler, be.CapacityScaler)
+	}
+	if ownCapacity == 0 || otherCapacity == 0 {
+		// Without endpoints the capacity scaler does not matter, and
+		// without other capacity this cluster takes all the traffic.
+		return 1, nil
+	}
+	capacity := share / (1 - share) * otherCapacity / ownCapacity
+	capacity /= math.Max(capacity, maxScaler)
+	return math.Round(capacity*100) / 100, nil
+}
+
+// backendCapacity returns the maximum rate or connections of a backend with
+// the given endpoints, or the endpoints for other balancing modes
+func backendCapacity(be compute.Backend, endpoints int64) float64 {
+	switch {
+	case be.MaxRate > 0:
+		return float64(be.MaxRate)
+	case be.MaxConnections > 0:
+		return float64(be.MaxConnections)
+	case be.MaxRatePerEndpoint > 0:
+		return float64(endpoints) * be.MaxRatePerEndpoint
+	case be.MaxConnectionsPerEndpoint > 0:
+		return float64(endpoints * be.MaxConnectionsPerEndpoint)
+	}
+	return float64(endpoints)
+}
+
+// negSize returns the number of endpoints of a zonal NEG. Other backends,
+// and NEGs which no longer exist, have no endpoints.
+func (b *ProdBackendController) negSize(ctx context.Context, group string) (int64, error) {
+	matches := negRE.FindStringSubmatch(group)
+	if len(matches) < 4 {
+		return 0, nil
+	}
+	if err := b.wait(ctx, computeCallRead); err != nil {
+		return 0, err
+	}
+	neg, err := compute.NewNetworkEndpointGroupsService(b.s).Get(matches[1], matches[2], matches[3]).Do()
+	b.observeCall(err)
+	if e, ok := err.(*googleapi.Error); ok && e.Code == 404 {
+		return 0, nil
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to get gcp network endpoint group", "group", group)
+		return 0, err
+	}
+	return neg.Size, nil
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"testing"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+func TestTrafficSplitShares(t *testing.T) {
+	split, err := parseTrafficSplit(`{"test": {"a": 70, "b": 20, "c": 10}, "europe-west4/test": {"a": 1, "b": 1}}`)
+	if err != nil {
+		t.Fatalf("parseTrafficSplit() got err: %v", err)
+	}
+	regional := AutonegConfig{BackendServices: map[string]map[string]AutonegNEGConfig{
+		"80": {"test": {Name: "test", Region: "europe-west4"}},
+	}}
+	tests := []struct {
+		name    string
+		cluster string
+		config  AutonegConfig
+		want    map[string]float64
+	}{
+		{
+			name:    "global backend service",
+			cluster: "b",
+			config:  configBasic,
+			want:    map[string]float64{"test": 0.2},
+		},
+		{
+			name:    "regional backend service",
+			cluster: "a",
+			config:  regional,
+			want:    map[string]float64{"europe-west4/test": 0.5},
+		},
+		{
+			name:    "cluster not in split",
+			cluster: "d",
+			config:  configBasic,
+		},
+		{
+			name:   "no cluster name",
+			config: configBasic,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := split.shares(tt.cluster, tt.config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("shares() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, invalid := range []string{`[]`, `{"test": {"a": -1, "b": 2}}`, `{"test": {"a": 0}}`} {
+		if _, err := parseTrafficSplit(invalid); err == nil {
+			t.Errorf("parseTrafficSplit(%s) got no error", invalid)
+		}
+	}
+}
+
+func TestSplitCapacity(t *testing.T) {
+	sizes := map[string]int64{fakeNeg: 5, "a": 10, "c": 10}
+	s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(compute.NetworkEndpointGroup{Size: sizes[path.Base(req.URL.Path)]})
+	}))
+	defer s.Close()
+	cs, err := compute.NewService(context.Background(), option.WithEndpoint(s.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("Failed to instantiate compute service: %v", err)
+	}
+	bc := ProdBackendController{project: fakeProject, s: cs}
+
+	// This cluster has 10 endpoints in two zones.
+	backends := backendsBasicWithNEGs["80"]["test"].backends
+	other := func(neg string, capacity float64) *compute.Backend {
+		return &compute.Backend{Group: getGroup(fakeProject, "zone1", neg), MaxRatePerEndpoint: 100, CapacityScaler: capacity}
+	}
+	own := func() []*compute.Backend {
+		var own []*compute.Backend
+		for _, be := range backends {
+			own = append(own, &be)
+		}
+		return own
+	}
+	tests := []struct {
+		name   string
+		share  float64
+		others []*compute.Backend
+		want   float64
+	}{
+		{
+			name:   "share of other capacity",
+			share:  0.2,
+			others: []*compute.Backend{other("a", 1), other("c", 0.2)},
+			want:   0.3,
+		},
+		{
+			name:   "scaled up to other clusters converging to full capacity",
+			share:  0.2,
+			others: []*compute.Backend{other("a", 0.5), other("c", 0.2)},
+			want:   0.35,
+		},
+		{
+			name:   "largest share",
+			share:  0.7,
+			others: []*compute.Backend{other("a", 1), other("c", 1)},
+			want:   1,
+		},
+		{
+			name:  "no other backends",
+			share: 0.2,
+			want:  1,
+		},
+		{
+			name:   "no share",
+			share:  0,
+			others: []*compute.Backend{other("a", 1)},
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &compute.BackendService{Name: "test", Backends: append(own(), tt.others...)}
+			got, err := bc.splitCapacity(context.Background(), svc, backends, tt.share)
+			if err != nil {
+				t.Fatalf("splitCapacity() got err: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("splitCapacity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,9 +17,12 @@ limitations under the License.
 package controllers
 
 import (
+	"regexp"
 	"strconv"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
 	"google.golang.org/api/compute/v1"
 )
 
@@ -74,11 +77,50 @@ type AutonegNEGConfig struct {
 	CustomMetrics   []AutonegCustomMetric `json:"custom_metrics,omitempty"`
 	InitialCapacity *StringOrInt          `json:"initial_capacity,omitempty"`
 	CapacityScaler  *StringOrInt          `json:"capacity_scaler,omitempty"`
+	Failover        bool                  `json:"failover,omitempty"`
+	Description     string                `json:"description,omitempty"`
+	// Sync overrides the sync configuration of the service for this
+	// backend service
+	Sync *AutonegSyncConfig `json:"sync,omitempty"`
+	// Zones overrides settings of the backends in the given zones
+	Zones map[string]AutonegZoneConfig `json:"zones,omitempty"`
+	// WeightByEndpoints sets the maximum rate or connections of each
+	// backend to the per endpoint setting times the ready endpoints of
+	// the service in its zone
+	WeightByEndpoints bool `json:"weight_by_endpoints,omitempty"`
+	// DetachEmptyZones leaves the backends of zones without ready
+	// endpoints of the service detached
+	DetachEmptyZones bool `json:"detach_empty_zones,omitempty"`
 }
 
-// AutonegSyncConfig specifies additional configuration which to sync
+// AutonegZoneConfig overrides settings of the backend of a zone. Overridden
+// settings are always kept in sync.
+type AutonegZoneConfig struct {
+	CapacityScaler *StringOrInt   `json:"capacity_scaler,omitempty"`
+	Rate           *StringOrFloat `json:"max_rate_per_endpoint,omitempty"`
+	Connections    *StringOrFloat `json:"max_connections_per_endpoint,omitempty"`
+}
+
+// AutonegSyncConfig specifies which backend fields autoneg owns, i.e.
+// keeps in sync with the configuration. Fields which are not owned are set
+// when a backend is added and left to other tools afterwards. Balancing
+// mode, rates and custom metrics are owned unless disabled, capacity
+// scaler, failover and description only if enabled.
 type AutonegSyncConfig struct {
 	CapacityScaler *bool `json:"capacity_scaler,omitempty"`
+	BalancingMode  *bool `json:"balancing_mode,omitempty"`
+	Rates          *bool `json:"rates,omitempty"`
+	CustomMetrics  *bool `json:"custom_metrics,omitempty"`
+	Failover       *bool `json:"failover,omitempty"`
+	Description    *bool `json:"description,omitempty"`
+}
+
+// AutonegOperation references a compute operation started by autoneg
+// which has not finished yet
+type AutonegOperation struct {
+	Name           string `json:"name"`
+	Region         string `json:"region,omitempty"`
+	BackendService string `json:"backend_service,omitempty"`
 }
 
 // AutonegStatus specifies the reconciled status of autoneg
@@ -87,15 +129,73 @@ type AutonegStatus struct {
 	AutonegConfig
 	NEGStatus
 	AutonegSyncConfig *AutonegSyncConfig `json:"sync,omitempty"`
+	Operations        []AutonegOperation `json:"operations,omitempty"`
+	Drain             *AutonegDrain      `json:"drain,omitempty"`
+	// Endpoints holds the ready endpoints of the service by zone, if any
+	// backend service is weighted by endpoints or detaches empty zones
+	Endpoints map[string]int `json:"endpoints,omitempty"`
+
+	// driftPolicy is the drift policy of the service, it is not persisted
+	driftPolicy string
+	// drainStep is the maximum change of capacity scalers of drained or
+	// restored backends per reconciliation, zero for no limit; it is not
+	// persisted
+	drainStep float64
+	// trafficSplit is the share of traffic of this cluster, by traffic
+	// split key of the backend service; it is not persisted
+	trafficSplit map[string]float64
+	// serviceKey is the namespace/name of the service, it is not persisted
+	serviceKey string
+	// changePriority orders the changes of the service while the change
+	// budget is exhausted; it is not persisted
+	changePriority int
+	// frozen refuses all changes of backend services; it is not persisted
+	frozen bool
+	// deferChanges defers all changes but removals of backends during a
+	// freeze window; it is not persisted
+	deferChanges bool
+	// protected are the patterns of backend services which are only
+	// changed once the plan of the changes was approved; it is not
+	// persisted
+	protected []*regexp.Regexp
+	// approvedPlan is the hash of the approved plan, it is not persisted
+	approvedPlan string
+}
+
+// AutonegDrain records the zones whose backends autoneg drained, and the
+// capacity scalers the drained backends had before, keyed by NEG URL
+type AutonegDrain struct {
+	// Cluster drains the backends in all zones
+	Cluster    bool               `json:"cluster,omitempty"`
+	Zones      []string           `json:"zones,omitempty"`
+	Capacities map[string]float64 `json:"capacities,omitempty"`
+	// Restoring holds the capacity scalers of backends which are
+	// gradually restored after a drain
+	Restoring map[string]float64 `json:"restoring,omitempty"`
+	// InProgress is set while backends are stepped towards their drained
+	// or restored capacity scalers
+	InProgress bool `json:"in_progress,omitempty"`
 }
 
 // Statuses represents the autoneg-relevant structs fetched from annotations
 type Statuses struct {
-	config     AutonegConfig
-	status     AutonegStatus
-	negStatus  NEGStatus
-	negConfig  NEGConfig
-	syncConfig *AutonegSyncConfig
+	config      AutonegConfig
+	status      AutonegStatus
+	negStatus   NEGStatus
+	negConfig   NEGConfig
+	syncConfig  *AutonegSyncConfig
+	driftPolicy string
+	// missingNEGs are the NEGs of the neg-status annotation without svcneg
+	// object
+	missingNEGs []string
+	// maxBackendRemoval is the maximum percentage of backends a single
+	// reconciliation may remove, 0 for no limit
+	maxBackendRemoval int
+	// allowBackendRemoval overrides the maximum once
+	allowBackendRemoval bool
+	// changePriority orders the changes of the service while the change
+	// budget is exhausted
+	changePriority int
 }
 
 // Backends specifies a name and list of compute.Backends
@@ -109,6 +209,29 @@ type Backends struct {
 type ProdBackendController struct {
 	project string
 	s       *compute.Service
+
+	readLimiter      *rate.Limiter
+	writeLimiter     *rate.Limiter
+	operationLimiter *rate.Limiter
+
+	cache     *backendServiceCache
+	budget    *changeBudget
+	permitted *permittedBackendServices
+	health    computeHealth
+
+	MetricThrottledSeconds   *prometheus.HistogramVec
+	MetricCacheRequests      *prometheus.CounterVec
+	MetricChangeBudgetQueued prometheus.Gauge
+}
+
+// BackendControllerOptions configures a ProdBackendController
+type BackendControllerOptions struct {
+	RateLimits   ComputeRateLimits
+	Cache        BackendServiceCacheOptions
+	ChangeBudget ChangeBudgetOptions
+	// CheckPermissions tests the permissions on each backend service
+	// before its first use
+	CheckPermissions bool
 }
 
 // NEGConfig specifies the configuration stored in
@@ -18,8 +18,11 @@ package controllers
 
 import (
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"strings"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 var maxNameLength = 63
@@ -116,3 +119,22 @@ func TrimFieldsEvenly(max int, fields ...string) []string {
 
 	return ret
 }
+
+// serviceHash returns a hash of the parts of a service autoneg acts upon:
+// its spec, its annotations (except the ones written by autoneg) and
+// whether it is being deleted.
+func serviceHash(svc *corev1.Service) string {
+	annotations := make(map[string]string, len(svc.ObjectMeta.Annotations))
+	for k, v := range svc.ObjectMeta.Annotations {
+		if k == autonegStatusAnnotation {
+			continue
+		}
+		annotations[k] = v
+	}
+	data, _ := json.Marshal(struct {
+		Spec        corev1.ServiceSpec
+		Annotations map[string]string
+		Deleting    bool
+	}{svc.Spec, annotations, !svc.ObjectMeta.DeletionTimestamp.IsZero()})
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Sources of the zones of the NEGs of a service
+const (
+	// ZoneSourceSvcNeg reads the zones from the ServiceNetworkEndpointGroup
+	// custom resources of the NEG controller
+	ZoneSourceSvcNeg = "svcneg"
+	// ZoneSourceNEGStatus reads the zones from the cloud.google.com/neg-status
+	// annotation
+	ZoneSourceNEGStatus = "neg-status"
+	// ZoneSourceEndpointSlices reads the zones from the topology of the
+	// endpoints of the service
+	ZoneSourceEndpointSlices = "endpointslices"
+)
+
+const (
+	// conditionNEGsNotReady reports NEGs of the neg-status annotation which
+	// have no svcneg object yet, while the previous zones are kept.
+	conditionNEGsNotReady = "NEGsNotReady"
+	// missingNEGsRequeue is how soon a service with missing svcneg objects
+	// is reconciled again
+	missingNEGsRequeue = 10 * time.Second
+)
+
+// IsValidZoneSource returns true for the known zone sources
+func IsValidZoneSource(source string) bool {
+	switch source {
+	case ZoneSourceSvcNeg, ZoneSourceNEGStatus, ZoneSourceEndpointSlices:
+		return true
+	}
+	return false
+}
+
+// zoneSource returns the zone source of the reconciler, defaulting to the
+// svcneg custom resources if they are used
+func (r *ServiceReconciler) zoneSource() string {
+	if r.ZoneSource != "" {
+		return r.ZoneSource
+	}
+	if r.UseSvcNeg {
+		return ZoneSourceSvcNeg
+	}
+	return ZoneSourceNEGStatus
+}
+
+// zonesFromEndpointSlices returns the sorted zones of the endpoints of the
+// service
+func zonesFromEndpointSlices(ctx context.Context, reader client.Reader, namespace string, name string) ([]string, error) {
+	endpointSlices := &discoveryv1.EndpointSliceList{}
+	if err := reader.List(ctx, endpointSlices, client.InNamespace(namespace), client.MatchingLabels{discoveryv1.LabelServiceName: name}); err != nil {
+		return nil, err
+	}
+	zones := []string{}
+	for _, slice := range endpointSlices.Items {
+		for _, ep := range slice.Endpoints {
+			if ep.Zone != nil && *ep.Zone != "" {
+				zones = append(zones, *ep.Zone)
+			}
+		}
+	}
+	slices.Sort(zones)
+	return slices.Compact(zones), nil
+}
+
+// checkZones logs when the zones of the zone source disagree with the zones
+// of the cloud.google.com/neg-status annotation, and returns false then.
+func checkZones(logger logr.Logger, source string, zones []string, negStatusZones []string) bool {
+	sorted := func(zones []string) []string {
+		zones = slices.Clone(zones)
+		slices.Sort(zones)
+		return slices.Compact(zones)
+	}
+	if slices.Equal(sorted(zones), sorted(negStatusZones)) {
+		return true
+	}
+	logger.Info("Zone sources disagree", "source", source, "zones", zones, "negStatusZones", negStatusZones)
+	return false
+}
+
+// reportMissingNEGs records NEGs without svcneg object as a warning event and
+// the NEGsNotReady condition, which is removed once all of them are found.
+func (r *ServiceReconciler) reportMissingNEGs(ctx context.Context, logger logr.Logger, svc *corev1.Service, missing []string) {
+	if len(missing) == 0 {
+		if err := r.removeCondition(ctx, svc, conditionNEGsNotReady); err != nil {
+			logger.Error(err, "Failed to update service status")
+		}
+		return
+	}
+	message := fmt.Sprintf("No svcneg object found for NEGs %s", strings.Join(missing, ", "))
+	if r.ProtectMissingSvcNeg {
+		message += ", keeping the previous zones"
+	}
+	r.Recorder.Event(svc, "Warning", "SvcNegNotFound", message)
+	if err := r.setCondition(ctx, svc, conditionNEGsNotReady, metav1.ConditionTrue, "SvcNegNotFound", message); err != nil {
+		logger.Error(err, "Failed to update service status")
+	}
+}
+
+// requeueWithin shortens the requeue delay of a result to at most d
+func requeueWithin(res reconcile.Result, d time.Duration) reconcile.Result {
+	if res.RequeueAfter == 0 || res.RequeueAfter > d {
+		res.RequeueAfter = d
+	}
+	return res
+}
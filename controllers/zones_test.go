@@ -0,0 +1,193 @@
+/*
+Copyright 2026 Google LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/ingress-gce/pkg/apis/svcneg/v1beta1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestZoneSourceEndpointSlices(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler()
+	r.ZoneSource = ZoneSourceEndpointSlices
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta:  metav1.ObjectMeta{Namespace: "ns", Name: "test-1", Labels: map[string]string{discoveryv1.LabelServiceName: "test"}},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"10.0.0.2"}, Zone: ptr.To("zone2")},
+			{Addresses: []string{"10.0.0.1"}, Zone: ptr.To("zone1")},
+			{Addresses: []string{"10.0.0.3"}, Zone: ptr.To("zone2")},
+			{Addresses: []string{"10.0.0.4"}},
+		},
+	}
+	if err := r.Create(ctx, slice); err != nil {
+		t.Fatalf("Create() got err: %v", err)
+	}
+
+	// The neg-status annotation lags and lists a zone without endpoints.
+	annotations := map[string]string{
+		autonegAnnotation:   validConfig,
+		negStatusAnnotation: `{"network_endpoint_groups":{"80":"neg_name"},"zones":["zone1","zone3"]}`,
+	}
+	statuses, valid, err := getStatuses(ctx, "ns", "test", annotations, r)
+	if err != nil || !valid {
+		t.Fatalf("getStatuses() got valid %v, err %v", valid, err)
+	}
+	if want := []string{"zone1", "zone2"}; !reflect.DeepEqual(statuses.negStatus.Zones, want) {
+		t.Errorf("getStatuses() got zones %v, want %v", statuses.negStatus.Zones, want)
+	}
+
+	// Without any endpoints, the reconciled zones are kept.
+	if err := r.Delete(ctx, slice); err != nil {
+		t.Fatalf("Delete() got err: %v", err)
+	}
+	annotations[autonegStatusAnnotation] = `{"backend_services":{},"network_endpoint_groups":{"80":"neg_name"},"zones":["zone1","zone2"]}`
+	statuses, valid, err = getStatuses(ctx, "ns", "test", annotations, r)
+	if err != nil || !valid {
+		t.Fatalf("getStatuses() got valid %v, err %v", valid, err)
+	}
+	if want := []string{"zone1", "zone2"}; !reflect.DeepEqual(statuses.negStatus.Zones, want) {
+		t.Errorf("getStatuses() without endpoints got zones %v, want %v", statuses.negStatus.Zones, want)
+	}
+
+	if checkZones(logr.Discard(), ZoneSourceEndpointSlices, []string{"zone1", "zone2"}, []string{"zone1", "zone3"}) {
+		t.Errorf("checkZones() of disagreeing sources = true, want false")
+	}
+	if !checkZones(logr.Discard(), ZoneSourceSvcNeg, []string{"zone2", "zone1"}, []string{"zone1", "zone2"}) {
+		t.Errorf("checkZones() of agreeing sources = false, want true")
+	}
+}
+
+func TestZoneSourceDefault(t *testing.T) {
+	r := &ServiceReconciler{UseSvcNeg: true}
+	if got := r.zoneSource(); got != ZoneSourceSvcNeg {
+		t.Errorf("zoneSource() with svcneg = %q, want %q", got, ZoneSourceSvcNeg)
+	}
+	r.UseSvcNeg = false
+	if got := r.zoneSource(); got != ZoneSourceNEGStatus {
+		t.Errorf("zoneSource() without svcneg = %q, want %q", got, ZoneSourceNEGStatus)
+	}
+	if IsValidZoneSource("nodes") {
+		t.Errorf("IsValidZoneSource(nodes) = true, want false")
+	}
+}
+
+func TestReconcileMissingSvcNeg(t *testing.T) {
+	ctx := context.Background()
+	key := types.NamespacedName{Namespace: "ns", Name: "svc"}
+	testScheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(testScheme); err != nil {
+		t.Fatalf("AddToScheme() got err: %v", err)
+	}
+	if err := v1beta1.AddToScheme(testScheme); err != nil {
+		t.Fatalf("AddToScheme() got err: %v", err)
+	}
+	r := newTestReconciler()
+	r.Client = fake.NewClientBuilder().
+		WithScheme(testScheme).
+		WithStatusSubresource(&corev1.Service{}).
+		WithObjects(&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: key.Namespace,
+				Name:      key.Name,
+				Annotations: map[string]string{
+					autonegAnnotation:   `{"backend_services":{"80":[{"name":"test","max_rate_per_endpoint":100}]}}`,
+					negStatusAnnotation: `{"network_endpoint_groups":{"80":"neg_name"},"zones":["zone1","zone2"]}`,
+				},
+			},
+		}).
+		Build()
+	r.UseSvcNeg = true
+	r.ProtectMissingSvcNeg = true
+	req := ctrl.Request{NamespacedName: key}
+
+	svcNeg := func(zones ...string) *v1beta1.ServiceNetworkEndpointGroup {
+		neg := &v1beta1.ServiceNetworkEndpointGroup{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: "neg_name"}}
+		for _, zone := range zones {
+			neg.Status.NetworkEndpointGroups = append(neg.Status.NetworkEndpointGroups, v1beta1.NegObjectReference{
+				SelfLink: getGroup(fakeProject, zone, "neg_name"),
+			})
+		}
+		return neg
+	}
+	getService := func() (*corev1.Service, AutonegStatus) {
+		svc := &corev1.Service{}
+		if err := r.Get(ctx, key, svc); err != nil {
+			t.Fatalf("Get() got err: %v", err)
+		}
+		var status AutonegStatus
+		if err := json.Unmarshal([]byte(svc.Annotations[autonegStatusAnnotation]), &status); err != nil {
+			t.Fatalf("failed to decode status: %v", err)
+		}
+		return svc, status
+	}
+
+	neg := svcNeg("zone1", "zone2")
+	if err := r.Create(ctx, neg); err != nil {
+		t.Fatalf("Create() got err: %v", err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("Reconcile() got err: %v", err)
+	}
+
+	// The svcneg disappears, the previous zones are kept.
+	if err := r.Delete(ctx, neg); err != nil {
+		t.Fatalf("Delete() got err: %v", err)
+	}
+	res, err := r.Reconcile(ctx, req)
+	if err != nil || res.RequeueAfter != missingNEGsRequeue {
+		t.Fatalf("Reconcile() got %+v, %v, want requeue after %v", res, err, missingNEGsRequeue)
+	}
+	svc, status := getService()
+	if want := []string{"zone1", "zone2"}; !reflect.DeepEqual(status.Zones, want) {
+		t.Errorf("Reconcile() stored zones %v, want %v", status.Zones, want)
+	}
+	if !meta.IsStatusConditionTrue(svc.Status.Conditions, conditionNEGsNotReady) {
+		t.Errorf("Service has no %s condition", conditionNEGsNotReady)
+	}
+
+	// The svcneg is back with fewer zones.
+	if err := r.Create(ctx, svcNeg("zone1")); err != nil {
+		t.Fatalf("Create() got err: %v", err)
+	}
+	if res, err = r.Reconcile(ctx, req); err != nil || res.RequeueAfter != 0 {
+		t.Fatalf("Reconcile() got %+v, %v, want no requeue", res, err)
+	}
+	svc, status = getService()
+	if want := []string{"zone1"}; !reflect.DeepEqual(status.Zones, want) {
+		t.Errorf("Reconcile() stored zones %v, want %v", status.Zones, want)
+	}
+	if meta.FindStatusCondition(svc.Status.Conditions, conditionNEGsNotReady) != nil {
+		t.Errorf("Service still has the %s condition", conditionNEGsNotReady)
+	}
+}
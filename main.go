@@ -32,25 +32,38 @@ import (
 	"k8s.io/klog/v2"
 
 	"cloud.google.com/go/compute/metadata"
+	"google.golang.org/api/cloudresourcemanager/v1"
 	"google.golang.org/api/compute/v1"
 	"google.golang.org/api/option"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/ingress-gce/pkg/apis/svcneg/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
+	"github.com/GoogleCloudPlatform/gke-autoneg-controller/api/v1alpha1"
 	"github.com/GoogleCloudPlatform/gke-autoneg-controller/controllers"
 	//+kubebuilder:scaffold:imports
 )
 
 const useragent = "google-pso-tool/gke-autoneg-controller/2.0.0"
 
+// leaderElectionID is the name of the leader election lease
+const leaderElectionID = "9fe89c94.controller.autoneg.dev"
+
+// inClusterNamespaceFile holds the namespace of the pod, which also holds the
+// leader election lease
+const inClusterNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
 var (
 	scheme    = runtime.NewScheme()
 	setupLog  = ctrl.Log.WithName("setup")
@@ -81,7 +94,22 @@ func main() {
 	var useAuthorizationForMetrics bool
 	var leaderElectionLeaseDuration time.Duration
 	var leaderElectionRenewDeadline time.Duration
+	var leaderElectionNamespace string
 	var maximumErrors int
+	var computeRateLimits controllers.ComputeRateLimits
+	var backendServiceCache controllers.BackendServiceCacheOptions
+	var changeBudget controllers.ChangeBudgetOptions
+	var controllerConfigMap string
+	var clusterName string
+	var endpointWeighting bool
+	var watchEndpointZones bool
+	var zoneSource string
+	var protectMissingSvcNeg bool
+	var maxBackendRemovalPercent int
+	var namespacePolicy bool
+	var requireNamespacePolicy bool
+	var preflightPermissions bool
+	var policyWebhook bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&useAuthorizationForMetrics, "metrics-authorization", true, "Enforce authorization for metrics endpoint")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -93,6 +121,7 @@ func main() {
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.DurationVar(&leaderElectionLeaseDuration, "leader-elect-lease-duration", 30*time.Second, "Set leaser election lease duration (in seconds).")
 	flag.DurationVar(&leaderElectionRenewDeadline, "leader-elect-renew-deadline", 20*time.Second, "Set leaser election lease renewal deadline (in seconds).")
+	flag.StringVar(&leaderElectionNamespace, "leader-elect-namespace", "", "The namespace of the leader election lease. Defaults to the namespace of the pod.")
 	flag.StringVar(&serviceNameTemplate, "default-backendservice-name", "{name}-{port}",
 		"A naming template consists of {namespace}, {name}, {port} or {hash} separated by hyphens, "+
 			"where {hash} is the first 8 digits of a hash of other given information")
@@ -102,7 +131,28 @@ func main() {
 	flag.BoolVar(&deregisterNEGsOnAnnotationRemoval, "deregister-negs-on-annotation-removal", true, "Deregister NEGs from backend service when annotation removed.")
 	flag.StringVar(&project, "project-id", "", "The project ID of the Google Cloud project where the backend services are created. If not specified, project ID will be fetched from the Metadata server.")
 	flag.BoolVar(&useSvcNeg, "use-svcneg", true, "Use service neg custom resource to get the NEG zone info.")
+	flag.StringVar(&zoneSource, "zone-source", "", "Where to get the NEG zone info from: svcneg, neg-status or endpointslices. Defaults to svcneg with --use-svcneg, neg-status otherwise.")
+	flag.BoolVar(&protectMissingSvcNeg, "protect-missing-svcneg", true, "Keep the previous zones of a service while NEGs of its neg-status annotation have no svcneg object.")
+	flag.IntVar(&maxBackendRemovalPercent, "max-backend-removal-percent", 0, "Refuse reconciliations which remove more than this percentage of the backends of a service, or leave a backend service without backends (0 disables the guard).")
 	flag.IntVar(&maximumErrors, "maximum-errors", 0, "Maximum consecutive errors in reconciliation, until controller gives up (0 means unlimited). Defaults to 0.")
+	flag.Float64Var(&computeRateLimits.Reads.QPS, "compute-read-qps", 0, "Maximum rate of compute API read calls per second (0 means unlimited).")
+	flag.IntVar(&computeRateLimits.Reads.Burst, "compute-read-burst", 20, "Maximum burst of compute API read calls.")
+	flag.Float64Var(&computeRateLimits.Writes.QPS, "compute-write-qps", 0, "Maximum rate of compute API write calls per second (0 means unlimited).")
+	flag.IntVar(&computeRateLimits.Writes.Burst, "compute-write-burst", 10, "Maximum burst of compute API write calls.")
+	flag.Float64Var(&computeRateLimits.Operations.QPS, "compute-operation-qps", 0, "Maximum rate of compute API operation polls per second (0 means unlimited).")
+	flag.IntVar(&computeRateLimits.Operations.Burst, "compute-operation-burst", 20, "Maximum burst of compute API operation polls.")
+	flag.BoolVar(&backendServiceCache.Enabled, "backend-service-cache", true, "Cache backend services and revalidate them with conditional requests.")
+	flag.DurationVar(&backendServiceCache.RefreshInterval, "backend-service-cache-refresh-interval", 0, "Interval of listing all backend services to refresh the cache, e.g. 1m (0 disables the refresh).")
+	flag.IntVar(&changeBudget.MutationsPerMinute, "change-budget-mutations-per-minute", 0, "Maximum backend service mutations of all services per minute, excess changes are queued by priority (0 means unlimited).")
+	flag.IntVar(&changeBudget.BackendServicesPer10Minutes, "change-budget-backend-services-per-10m", 0, "Maximum distinct backend services changed per 10 minutes, excess changes are queued by priority (0 means unlimited).")
+	flag.StringVar(&controllerConfigMap, "controller-config", "", "The namespace/name of the ConfigMap holding settings which apply to all services, e.g. autoneg-system/autoneg-controller-config.")
+	flag.StringVar(&clusterName, "cluster-name", "", "The name of this cluster in traffic splits of the controller config.")
+	flag.BoolVar(&endpointWeighting, "endpoint-weighting", false, "Watch EndpointSlices to weight backends by the ready endpoints of services.")
+	flag.BoolVar(&watchEndpointZones, "watch-endpoint-zones", false, "Watch EndpointSlices to update backends as soon as zones gain or lose their ready endpoints.")
+	flag.BoolVar(&namespacePolicy, "namespace-policy", false, "Restrict the backend services of namespaces to the ones allowed by AutonegPolicy resources, if any.")
+	flag.BoolVar(&requireNamespacePolicy, "require-namespace-policy", false, "Deny all backend services while no AutonegPolicy resource exists, instead of allowing all of them.")
+	flag.BoolVar(&policyWebhook, "policy-webhook", false, "Serve an admission webhook rejecting services which target backend services not allowed by AutonegPolicy resources.")
+	flag.BoolVar(&preflightPermissions, "preflight-permissions", false, "Test the IAM permissions on the project before becoming ready, and on each backend service before its first use.")
 	flag.BoolVar(&debug, "debug", false, "Enable debug logging.")
 
 	opts := zap.Options{
@@ -118,9 +168,32 @@ func main() {
 	// Configure klog to use our zap logger for client-go components (like leader election)
 	klog.SetLogger(logger)
 
+	switch {
+	case zoneSource == "":
+		zoneSource = controllers.ZoneSourceNEGStatus
+		if useSvcNeg {
+			zoneSource = controllers.ZoneSourceSvcNeg
+		}
+	case !controllers.IsValidZoneSource(zoneSource):
+		setupLog.Error(fmt.Errorf("invalid zone source %s", zoneSource), "invalid zone source")
+		os.Exit(1)
+	default:
+		// The svcneg custom resources are only watched if they are the
+		// zone source.
+		useSvcNeg = zoneSource == controllers.ZoneSourceSvcNeg
+	}
+
+	if maxBackendRemovalPercent < 0 || maxBackendRemovalPercent > 100 {
+		setupLog.Error(fmt.Errorf("invalid maximum backend removal percentage %d", maxBackendRemovalPercent), "invalid maximum backend removal percentage")
+		os.Exit(1)
+	}
+
 	if useSvcNeg {
 		utilruntime.Must(v1beta1.AddToScheme(scheme))
 	}
+	if namespacePolicy || policyWebhook {
+		utilruntime.Must(v1alpha1.AddToScheme(scheme))
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -155,6 +228,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	var controllerConfigMapName types.NamespacedName
+	if controllerConfigMap != "" {
+		ns, name, ok := strings.Cut(controllerConfigMap, "/")
+		if !ok || ns == "" || name == "" {
+			err = fmt.Errorf("invalid controller config %s, expected namespace/name", controllerConfigMap)
+			setupLog.Error(err, "invalid controller config")
+			os.Exit(1)
+		}
+		controllerConfigMapName = types.NamespacedName{Namespace: ns, Name: name}
+	}
+
 	disableHTTP2 := func(c *tls.Config) {
 		setupLog.Info("disabling http/2 for metrics server")
 		c.NextProtos = []string{"http/1.1"}
@@ -173,12 +257,13 @@ func main() {
 		Scheme:  scheme,
 		Metrics: metricsServerOptions,
 		// Port:                   9443, // Webhook server will default to port 9443
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "9fe89c94.controller.autoneg.dev",
-		LeaseDuration:          &leaderElectionLeaseDuration,
-		RenewDeadline:          &leaderElectionRenewDeadline,
-		Logger:                 logger, // Ensure manager uses the same logger for leader election
+		HealthProbeBindAddress:  probeAddr,
+		LeaderElection:          enableLeaderElection,
+		LeaderElectionID:        leaderElectionID,
+		LeaderElectionNamespace: leaderElectionNamespace,
+		LeaseDuration:           &leaderElectionLeaseDuration,
+		RenewDeadline:           &leaderElectionRenewDeadline,
+		Logger:                  logger, // Ensure manager uses the same logger for leader election
 		NewCache: func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
 			if namespaces != "" {
 				opts.DefaultNamespaces = make(map[string]cache.Config, 0)
@@ -186,6 +271,18 @@ func main() {
 					opts.DefaultNamespaces[ns] = cache.Config{}
 				}
 			}
+			if controllerConfigMapName.Name != "" {
+				// Only cache the controller ConfigMap
+				opts.ByObject = map[client.Object]cache.ByObject{
+					&corev1.ConfigMap{}: {
+						Namespaces: map[string]cache.Config{
+							controllerConfigMapName.Namespace: {
+								FieldSelector: fields.OneTermEqualSelector("metadata.name", controllerConfigMapName.Name),
+							},
+						},
+					},
+				}
+			}
 			return cache.New(config, opts)
 		},
 	}
@@ -196,10 +293,22 @@ func main() {
 		os.Exit(1)
 	}
 
+	backendController := controllers.NewBackendController(project, s, controllers.BackendControllerOptions{
+		RateLimits:       computeRateLimits,
+		Cache:            backendServiceCache,
+		ChangeBudget:     changeBudget,
+		CheckPermissions: preflightPermissions,
+	})
+	backendController.RegisterMetrics()
+	if err = mgr.Add(backendController); err != nil {
+		setupLog.Error(err, "unable to add backend service cache refresh")
+		os.Exit(1)
+	}
+
 	serviceReconciler := &controllers.ServiceReconciler{
 		Client:                            mgr.GetClient(),
 		Scheme:                            mgr.GetScheme(),
-		BackendController:                 controllers.NewBackendController(project, s),
+		BackendController:                 backendController,
 		Recorder:                          mgr.GetEventRecorderFor("autoneg-controller"),
 		ServiceNameTemplate:               serviceNameTemplate,
 		AllowServiceName:                  allowServiceName,
@@ -211,21 +320,75 @@ func main() {
 		UseSvcNeg:                         useSvcNeg,
 		MaxErrors:                         maximumErrors,
 		ErrorCount:                        make(map[string]int, 0),
+		ControllerConfigMap:               controllerConfigMapName,
+		ClusterName:                       clusterName,
+		EndpointWeighting:                 endpointWeighting,
+		WatchEndpointZones:                watchEndpointZones,
+		ZoneSource:                        zoneSource,
+		ProtectMissingSvcNeg:              protectMissingSvcNeg,
+		MaxBackendRemovalPercent:          maxBackendRemovalPercent,
+		NamespacePolicy:                   namespacePolicy,
+		RequireNamespacePolicy:            requireNamespacePolicy,
+		Project:                           project,
 	}
 	serviceReconciler.RegisterMetrics()
 	if err = serviceReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Service")
 		os.Exit(1)
 	}
+	if policyWebhook {
+		if err = serviceReconciler.SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Service")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up ready check")
-		os.Exit(1)
+	readyChecks := map[string]healthz.Checker{
+		"informer-sync":    controllers.CacheSyncCheck(mgr.GetCache()),
+		"compute-api":      backendController.ComputeAPICheck,
+		"operation-poller": backendController.OperationPollerCheck,
+	}
+	if enableLeaderElection && leaderElectionNamespace == "" {
+		if namespace, err := os.ReadFile(inClusterNamespaceFile); err == nil {
+			leaderElectionNamespace = strings.TrimSpace(string(namespace))
+		} else {
+			setupLog.Info("Leader election namespace unknown, skipping leader election ready check", "error", err.Error())
+		}
+	}
+	if enableLeaderElection && leaderElectionNamespace != "" {
+		leaderCheck := &controllers.LeaderCheck{
+			Elected:   mgr.Elected(),
+			Reader:    mgr.GetAPIReader(),
+			Namespace: leaderElectionNamespace,
+			Name:      leaderElectionID,
+		}
+		readyChecks["leader-election"] = leaderCheck.Check
+	}
+	for name, check := range readyChecks {
+		if err := mgr.AddReadyzCheck(name, check); err != nil {
+			setupLog.Error(err, "unable to set up ready check", "check", name)
+			os.Exit(1)
+		}
+	}
+	if preflightPermissions {
+		crm, err := cloudresourcemanager.NewService(ctx, option.WithUserAgent(useragent))
+		if err != nil {
+			setupLog.Error(err, "can't request Google cloud resource manager service")
+			os.Exit(1)
+		}
+		permissionCheck := &controllers.ProjectPermissionCheck{Project: project, Service: crm}
+		if err := permissionCheck.Check(nil); err != nil {
+			setupLog.Error(err, "permission check failed, the controller is not ready until the permissions are granted")
+		}
+		if err := mgr.AddReadyzCheck("iam-permissions", permissionCheck.Check); err != nil {
+			setupLog.Error(err, "unable to set up permission check")
+			os.Exit(1)
+		}
 	}
 
 	setupLog.Info(fmt.Sprintf("Build time: %s", BuildTime))
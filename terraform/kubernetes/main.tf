@@ -115,6 +115,24 @@ resource "kubernetes_cluster_role_v1" "clusterrole_autoneg_manager_role" {
     resources  = ["servicenetworkendpointgroups"]
     verbs      = ["get", "list", "watch"]
   }
+
+  rule {
+    api_groups = ["discovery.k8s.io"]
+    resources  = ["endpointslices"]
+    verbs      = ["get", "list", "watch"]
+  }
+
+  rule {
+    api_groups = [""]
+    resources  = ["namespaces"]
+    verbs      = ["get", "list", "watch"]
+  }
+
+  rule {
+    api_groups = ["controller.autoneg.dev"]
+    resources  = ["autonegpolicies"]
+    verbs      = ["get", "list", "watch"]
+  }
 }
 
 resource "kubernetes_cluster_role_v1" "clusterrole_autoneg_metrics_reader" {